@@ -3,18 +3,28 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
+	_ "time/tzdata" // Schedule time zones must load on images without zoneinfo
 
+	"flowguard/internal/audit"
+	"flowguard/internal/capture"
 	"flowguard/internal/config"
 	"flowguard/internal/limiter"
+	"flowguard/internal/logging"
 	"flowguard/internal/metrics"
 	"flowguard/internal/proxy"
+	"flowguard/internal/tracing"
 	"flowguard/internal/types"
 )
 
@@ -24,6 +34,60 @@ type Config struct {
 	MetricsPort     string
 	ConfigPort      string
 	GRPCPort        string
+
+	// Upstream pool settings
+	UpstreamWeights     string
+	LBStrategy          string
+	HealthCheckPath     string
+	HealthCheckInterval time.Duration
+	EjectAfter          int
+	EjectDuration       time.Duration
+
+	// Retry and circuit breaker settings
+	MaxRetries       int
+	RetryBackoff     time.Duration
+	RetryMaxBackoff  time.Duration
+	RetryMaxBody     int64
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+
+	// Adaptive upstream limiter settings
+	AdaptiveLimits         bool
+	AdaptiveMaxRPM         int64
+	AdaptiveMinRPM         int64
+	AdaptiveDecreaseFactor float64
+	AdaptiveLowWatermark   float64
+
+	// Provider failover routes and rewrite rules (JSON files)
+	RoutesConfig  string
+	RewriteConfig string
+	CaptureConfig string
+
+	// Limit templates and client rules (JSON files) and unknown client settings
+	TemplatesConfig     string
+	RulesConfig         string
+	DefaultTemplate     string
+	UnknownClientPolicy string
+	DefaultClientID     string
+	MaxAutoClients      int
+	AutoClientIdleTTL   time.Duration
+
+	// Admin audit log file (empty keeps the audit log in memory)
+	AuditLogFile string
+
+	// Tracing settings
+	TraceExporter    string
+	OTLPEndpoint     string
+	TraceSampleRatio float64
+
+	// Logging settings
+	LogOutput            string
+	LogLevel             string
+	LogMaxSizeMB         int
+	LogMaxBackups        int
+	AccessLogFields      string
+	AccessLogSampleRate  float64
+	AccessLogClientRates string
 }
 
 func main() {
@@ -34,40 +98,283 @@ func main() {
 		MetricsPort: getEnvOrDefault("METRICS_PORT", "9090"),
 		ConfigPort:  getEnvOrDefault("CONFIG_PORT", "9091"),
 		GRPCPort:    getEnvOrDefault("GRPC_PORT", "9092"),
+
+		UpstreamWeights:     getEnvOrDefault("UPSTREAM_WEIGHTS", ""),
+		LBStrategy:          getEnvOrDefault("LB_STRATEGY", string(proxy.StrategyRoundRobin)),
+		HealthCheckPath:     getEnvOrDefault("HEALTH_CHECK_PATH", ""),
+		HealthCheckInterval: getEnvDurationOrDefault("HEALTH_CHECK_INTERVAL", 10*time.Second),
+		EjectAfter:          getEnvIntOrDefault("EJECT_AFTER", 5),
+		EjectDuration:       getEnvDurationOrDefault("EJECT_DURATION", 30*time.Second),
+
+		MaxRetries:       getEnvIntOrDefault("MAX_RETRIES", 2),
+		RetryBackoff:     getEnvDurationOrDefault("RETRY_BACKOFF", 100*time.Millisecond),
+		RetryMaxBackoff:  getEnvDurationOrDefault("RETRY_MAX_BACKOFF", 5*time.Second),
+		RetryMaxBody:     int64(getEnvIntOrDefault("RETRY_MAX_BODY_BYTES", 1<<20)),
+		BreakerThreshold: getEnvIntOrDefault("BREAKER_THRESHOLD", 10),
+		BreakerCooldown:  getEnvDurationOrDefault("BREAKER_COOLDOWN", 30*time.Second),
+
+		AdaptiveLimits:         getEnvBoolOrDefault("ADAPTIVE_LIMITS", false),
+		AdaptiveMaxRPM:         int64(getEnvIntOrDefault("ADAPTIVE_MAX_RPM", 600)),
+		AdaptiveMinRPM:         int64(getEnvIntOrDefault("ADAPTIVE_MIN_RPM", 10)),
+		AdaptiveDecreaseFactor: getEnvFloatOrDefault("ADAPTIVE_DECREASE_FACTOR", 0.5),
+		AdaptiveLowWatermark:   getEnvFloatOrDefault("ADAPTIVE_LOW_WATERMARK", 0.1),
+
+		RoutesConfig:  getEnvOrDefault("ROUTES_CONFIG", ""),
+		RewriteConfig: getEnvOrDefault("REWRITE_CONFIG", ""),
+		CaptureConfig: getEnvOrDefault("CAPTURE_CONFIG", ""),
+
+		TemplatesConfig:     getEnvOrDefault("TEMPLATES_CONFIG", ""),
+		RulesConfig:         getEnvOrDefault("RULES_CONFIG", ""),
+		DefaultTemplate:     getEnvOrDefault("DEFAULT_TEMPLATE", "free"),
+		UnknownClientPolicy: getEnvOrDefault("UNKNOWN_CLIENT_POLICY", limiter.UnknownClientCreate),
+		DefaultClientID:     getEnvOrDefault("DEFAULT_CLIENT_ID", "default"),
+		MaxAutoClients:      getEnvIntOrDefault("MAX_AUTO_CLIENTS", 10000),
+		AutoClientIdleTTL:   getEnvDurationOrDefault("AUTO_CLIENT_IDLE_TTL", time.Hour),
+
+		AuditLogFile: getEnvOrDefault("AUDIT_LOG_FILE", ""),
+
+		TraceExporter:    getEnvOrDefault("TRACE_EXPORTER", tracing.ExporterNone),
+		OTLPEndpoint:     getEnvOrDefault("OTLP_ENDPOINT", "http://localhost:4318"),
+		TraceSampleRatio: getEnvFloatOrDefault("TRACE_SAMPLE_RATIO", 1.0),
+
+		LogOutput:            getEnvOrDefault("LOG_OUTPUT", "stdout"),
+		LogLevel:             getEnvOrDefault("LOG_LEVEL", "info"),
+		LogMaxSizeMB:         getEnvIntOrDefault("LOG_MAX_SIZE_MB", 100),
+		LogMaxBackups:        getEnvIntOrDefault("LOG_MAX_BACKUPS", 5),
+		AccessLogFields:      getEnvOrDefault("ACCESS_LOG_FIELDS", ""),
+		AccessLogSampleRate:  getEnvFloatOrDefault("ACCESS_LOG_SAMPLE_RATE", 1.0),
+		AccessLogClientRates: getEnvOrDefault("ACCESS_LOG_CLIENT_SAMPLE_RATES", ""),
 	}
 
-	flag.StringVar(&cfg.UpstreamURL, "upstream", cfg.UpstreamURL, "Upstream API URL")
+	flag.StringVar(&cfg.UpstreamURL, "upstream", cfg.UpstreamURL, "Upstream API URL (comma-separated for a pool of targets)")
 	flag.StringVar(&cfg.ProxyPort, "proxy-port", cfg.ProxyPort, "Proxy server port")
 	flag.StringVar(&cfg.MetricsPort, "metrics-port", cfg.MetricsPort, "Metrics server port")
 	flag.StringVar(&cfg.ConfigPort, "config-port", cfg.ConfigPort, "REST config API port")
 	flag.StringVar(&cfg.GRPCPort, "grpc-port", cfg.GRPCPort, "gRPC server port")
+	flag.StringVar(&cfg.UpstreamWeights, "upstream-weights", cfg.UpstreamWeights, "Comma-separated weights for the upstream targets")
+	flag.StringVar(&cfg.LBStrategy, "lb-strategy", cfg.LBStrategy, "Load balancing strategy (round_robin, least_outstanding, weighted)")
+	flag.StringVar(&cfg.HealthCheckPath, "health-check-path", cfg.HealthCheckPath, "Upstream health check path (empty disables active checks)")
+	flag.DurationVar(&cfg.HealthCheckInterval, "health-check-interval", cfg.HealthCheckInterval, "Interval between upstream health checks")
+	flag.IntVar(&cfg.EjectAfter, "eject-after", cfg.EjectAfter, "Consecutive upstream failures before a target is ejected (0 disables)")
+	flag.DurationVar(&cfg.EjectDuration, "eject-duration", cfg.EjectDuration, "How long an ejected upstream target is skipped")
+	flag.IntVar(&cfg.MaxRetries, "max-retries", cfg.MaxRetries, "Retries for idempotent or X-Retryable upstream requests (0 disables)")
+	flag.DurationVar(&cfg.RetryBackoff, "retry-backoff", cfg.RetryBackoff, "Backoff before the first retry (doubles per attempt, with jitter)")
+	flag.DurationVar(&cfg.RetryMaxBackoff, "retry-max-backoff", cfg.RetryMaxBackoff, "Maximum retry backoff and honored Retry-After delay")
+	flag.Int64Var(&cfg.RetryMaxBody, "retry-max-body-bytes", cfg.RetryMaxBody, "Largest request body buffered so it can be retried")
+	flag.IntVar(&cfg.BreakerThreshold, "breaker-threshold", cfg.BreakerThreshold, "Consecutive upstream failures that open the circuit breaker (0 disables)")
+	flag.DurationVar(&cfg.BreakerCooldown, "breaker-cooldown", cfg.BreakerCooldown, "How long the circuit breaker stays open before probing")
+	flag.BoolVar(&cfg.AdaptiveLimits, "adaptive-limits", cfg.AdaptiveLimits, "Throttle global admission based on upstream 429s and rate limit headers")
+	flag.Int64Var(&cfg.AdaptiveMaxRPM, "adaptive-max-rpm", cfg.AdaptiveMaxRPM, "Starting and maximum global admission rate")
+	flag.Int64Var(&cfg.AdaptiveMinRPM, "adaptive-min-rpm", cfg.AdaptiveMinRPM, "Minimum global admission rate")
+	flag.Float64Var(&cfg.AdaptiveDecreaseFactor, "adaptive-decrease-factor", cfg.AdaptiveDecreaseFactor, "Multiplier applied to the admission rate on throttling signals")
+	flag.Float64Var(&cfg.AdaptiveLowWatermark, "adaptive-low-watermark", cfg.AdaptiveLowWatermark, "Fraction of the provider limit remaining that triggers throttling")
+	flag.StringVar(&cfg.RoutesConfig, "routes-config", cfg.RoutesConfig, "JSON file with providers and model failover routes")
+	flag.StringVar(&cfg.RewriteConfig, "rewrite-config", cfg.RewriteConfig, "JSON file with model aliases and request rewriting rules")
+	flag.StringVar(&cfg.CaptureConfig, "capture-config", cfg.CaptureConfig, "JSON file enabling audit capture of request and response bodies")
+	flag.StringVar(&cfg.TemplatesConfig, "templates-config", cfg.TemplatesConfig, "JSON file with the limit templates to create at startup (default: free and standard)")
+	flag.StringVar(&cfg.RulesConfig, "rules-config", cfg.RulesConfig, "JSON file with client rules matching client IDs by pattern")
+	flag.StringVar(&cfg.DefaultTemplate, "default-template", cfg.DefaultTemplate, "Limit template of auto-created clients and the default client (empty for no limits)")
+	flag.StringVar(&cfg.UnknownClientPolicy, "unknown-client-policy", cfg.UnknownClientPolicy, "Handling of unconfigured client IDs (create, default, reject)")
+	flag.StringVar(&cfg.DefaultClientID, "default-client-id", cfg.DefaultClientID, "Client shared by unknown client IDs under the default policy")
+	flag.IntVar(&cfg.MaxAutoClients, "max-auto-clients", cfg.MaxAutoClients, "Auto-created clients kept before the least recently used is evicted (0 for no cap)")
+	flag.DurationVar(&cfg.AutoClientIdleTTL, "auto-client-idle-ttl", cfg.AutoClientIdleTTL, "Evict auto-created clients unused for this long (0 keeps them)")
+	flag.StringVar(&cfg.AuditLogFile, "audit-log-file", cfg.AuditLogFile, "JSONL file persisting the admin configuration audit log")
+	flag.StringVar(&cfg.TraceExporter, "trace-exporter", cfg.TraceExporter, "Trace exporter (none, otlp)")
+	flag.StringVar(&cfg.OTLPEndpoint, "otlp-endpoint", cfg.OTLPEndpoint, "OTLP/HTTP endpoint traces are exported to")
+	flag.Float64Var(&cfg.TraceSampleRatio, "trace-sample-ratio", cfg.TraceSampleRatio, "Fraction of new traces sampled (incoming sampled traces are always kept)")
+	flag.StringVar(&cfg.LogOutput, "log-output", cfg.LogOutput, "Log output (stdout, stderr or a file path)")
+	flag.StringVar(&cfg.LogLevel, "log-level", cfg.LogLevel, "Log level (debug, info, warn, error)")
+	flag.IntVar(&cfg.LogMaxSizeMB, "log-max-size-mb", cfg.LogMaxSizeMB, "Rotate the log file once it reaches this size (0 disables rotation)")
+	flag.IntVar(&cfg.LogMaxBackups, "log-max-backups", cfg.LogMaxBackups, "Number of rotated log files kept")
+	flag.StringVar(&cfg.AccessLogFields, "access-log-fields", cfg.AccessLogFields, "Comma-separated access log fields (empty logs all fields)")
+	flag.Float64Var(&cfg.AccessLogSampleRate, "access-log-sample-rate", cfg.AccessLogSampleRate, "Fraction of requests written to the access log")
+	flag.StringVar(&cfg.AccessLogClientRates, "access-log-client-sample-rates", cfg.AccessLogClientRates, "Per-client access log sample rates (client=rate,...)")
 	flag.Parse()
 
+	// Initialize structured logging; the standard logger writes through it as well
+	logger, logOutput, err := logging.New(logging.Config{
+		Output:     cfg.LogOutput,
+		Level:      cfg.LogLevel,
+		MaxSizeMB:  cfg.LogMaxSizeMB,
+		MaxBackups: cfg.LogMaxBackups,
+	})
+	if err != nil {
+		log.Fatalf("Failed to initialize logging: %v", err)
+	}
+	defer logOutput.Close()
+	slog.SetDefault(logger)
+
 	log.Printf("Starting FlowGuard with config: %+v", cfg)
 
+	// Initialize tracing
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:    cfg.TraceExporter,
+		Endpoint:    cfg.OTLPEndpoint,
+		ServiceName: "flowguard",
+		SampleRatio: cfg.TraceSampleRatio,
+	})
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+
 	// Initialize components
 	rateLimiter := limiter.NewManager()
+	if cfg.AdaptiveLimits {
+		rateLimiter.SetAdaptiveLimiter(limiter.NewAdaptiveLimiter(limiter.AdaptiveConfig{
+			MaxRPM:         cfg.AdaptiveMaxRPM,
+			MinRPM:         cfg.AdaptiveMinRPM,
+			DecreaseFactor: cfg.AdaptiveDecreaseFactor,
+			LowWatermark:   cfg.AdaptiveLowWatermark,
+		}))
+	}
+	templates := limiter.DefaultTemplates()
+	if cfg.TemplatesConfig != "" {
+		if templates, err = limiter.LoadTemplates(cfg.TemplatesConfig); err != nil {
+			log.Fatalf("Failed to load limit templates: %v", err)
+		}
+	}
+	for _, template := range templates {
+		if _, _, err := rateLimiter.SetTemplate(template, nil); err != nil {
+			log.Fatalf("Invalid limit template %q: %v", template.Name, err)
+		}
+	}
+	if cfg.RulesConfig != "" {
+		rules, err := limiter.LoadRules(cfg.RulesConfig)
+		if err != nil {
+			log.Fatalf("Failed to load client rules: %v", err)
+		}
+		for _, rule := range rules {
+			if _, err := rateLimiter.SetRule(rule, nil); err != nil {
+				log.Fatalf("Invalid client rule %q: %v", rule.Name, err)
+			}
+		}
+	}
+	if err := rateLimiter.SetRegistryConfig(buildRegistryConfig(cfg)); err != nil {
+		log.Fatalf("Invalid unknown client configuration: %v", err)
+	}
+
+	// Create upstream pool
+	poolConfig, err := buildPoolConfig(cfg)
+	if err != nil {
+		log.Fatalf("Invalid upstream configuration: %v", err)
+	}
+	upstreamPool, err := proxy.NewPool(poolConfig)
+	if err != nil {
+		log.Fatalf("Failed to create upstream pool: %v", err)
+	}
+	upstreamPool.StartHealthChecks()
+	defer upstreamPool.Stop()
 
 	// Create proxy handler
-	proxyHandler, err := proxy.NewHandler(cfg.UpstreamURL, rateLimiter)
+	proxyHandler, err := proxy.NewHandler(upstreamPool, rateLimiter)
 	if err != nil {
 		log.Fatalf("Failed to create proxy handler: %v", err)
 	}
 
+	// Create access logger
+	clientRates, err := parseSampleRates(cfg.AccessLogClientRates)
+	if err != nil {
+		log.Fatalf("Invalid access log sample rates: %v", err)
+	}
+	accessLog, err := logging.NewAccessLogger(logger, logging.AccessLogConfig{
+		Fields:      splitList(cfg.AccessLogFields),
+		SampleRate:  cfg.AccessLogSampleRate,
+		ClientRates: clientRates,
+	})
+	if err != nil {
+		log.Fatalf("Invalid access log configuration: %v", err)
+	}
+	proxyHandler.SetAccessLogger(accessLog)
+
+	// Create request rewriter
+	if cfg.RewriteConfig != "" {
+		rewriteConfig, err := proxy.LoadRewriteConfig(cfg.RewriteConfig)
+		if err != nil {
+			log.Fatalf("Failed to load rewrite rules: %v", err)
+		}
+		rewriter, err := proxy.NewRewriter(rewriteConfig)
+		if err != nil {
+			log.Fatalf("Invalid rewrite rules: %v", err)
+		}
+		proxyHandler.SetRewriter(rewriter)
+	}
+
+	// Create audit capture recorder
+	var recorder *capture.Recorder
+	if cfg.CaptureConfig != "" {
+		captureConfig, err := capture.LoadConfig(cfg.CaptureConfig)
+		if err != nil {
+			log.Fatalf("Failed to load capture config: %v", err)
+		}
+		recorder, err = capture.NewRecorder(captureConfig)
+		if err != nil {
+			log.Fatalf("Failed to create audit capture recorder: %v", err)
+		}
+		proxyHandler.SetRecorder(recorder)
+	}
+
+	// Create provider failover router
+	upstreamPools := []*proxy.Pool{upstreamPool}
+	if cfg.RoutesConfig != "" {
+		routerConfig, err := proxy.LoadRouterConfig(cfg.RoutesConfig)
+		if err != nil {
+			log.Fatalf("Failed to load routes: %v", err)
+		}
+		router, err := proxy.NewRouter(routerConfig, poolConfig)
+		if err != nil {
+			log.Fatalf("Failed to create provider router: %v", err)
+		}
+		proxyHandler.SetRouter(router)
+		upstreamPools = append(upstreamPools, router.Pools()...)
+	}
+
 	// Create metrics collector
 	metricsCollector := metrics.NewMetrics(rateLimiter)
+	rateLimiter.SetObserver(metricsCollector)
+	proxyHandler.SetObserver(metricsCollector)
+	for _, pool := range upstreamPools {
+		metricsCollector.RegisterUpstreamPool(pool)
+	}
+	if adaptive := rateLimiter.AdaptiveLimiter(); adaptive != nil {
+		metricsCollector.RegisterAdaptiveLimiter(adaptive)
+	}
+	if recorder != nil {
+		metricsCollector.RegisterCaptureRecorder(recorder)
+	}
 	metricsCollector.StartMetricsUpdater(5 * time.Second)
 
+	// Create admin audit log
+	auditLog, err := audit.NewLog(cfg.AuditLogFile)
+	if err != nil {
+		log.Fatalf("Failed to open audit log: %v", err)
+	}
+	defer auditLog.Close()
+	rateLimiter.SetOverrideExpiryHook(config.OverrideExpiryAuditHook(auditLog))
+
 	// Create REST API server
 	restServer := config.NewRESTServer(rateLimiter)
+	restServer.SetAuditLog(auditLog)
+	for _, pool := range upstreamPools {
+		restServer.RegisterUpstream(pool)
+	}
 
 	// Create gRPC server
 	grpcServer := config.NewGRPCServer(rateLimiter)
+	grpcServer.SetAuditLog(auditLog)
+	for _, pool := range upstreamPools {
+		grpcServer.RegisterUpstream(pool)
+	}
 
 	// Setup HTTP servers
 	var wg sync.WaitGroup
 	ctx, cancel := context.WithCancel(context.Background())
 
+	// Evict idle auto-created clients
+	rateLimiter.StartEviction(ctx)
+
+	// Move clients between their schedule windows
+	rateLimiter.StartScheduler(ctx)
+
 	// Start proxy server
 	wg.Add(1)
 	go func() {
@@ -122,6 +429,8 @@ func main() {
 		server := &http.Server{
 			Addr:    ":" + cfg.ConfigPort,
 			Handler: restServer,
+			// Cancel requests on shutdown so event streams end
+			BaseContext: func(net.Listener) context.Context { return ctx },
 		}
 		log.Printf("Starting REST config server on port %s", cfg.ConfigPort)
 		
@@ -168,6 +477,17 @@ func main() {
 	log.Println("Shutting down FlowGuard...")
 	cancel()
 	wg.Wait()
+
+	// Write any queued capture records once the proxy has stopped
+	if recorder != nil {
+		recorder.Close()
+	}
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer shutdownCancel()
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Printf("Failed to flush traces: %v", err)
+	}
 	log.Println("FlowGuard stopped")
 }
 
@@ -178,6 +498,127 @@ func getEnvOrDefault(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getEnvIntOrDefault(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+		log.Printf("Ignoring invalid integer for %s: %q", key, value)
+	}
+	return defaultValue
+}
+
+func getEnvBoolOrDefault(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			return parsed
+		}
+		log.Printf("Ignoring invalid boolean for %s: %q", key, value)
+	}
+	return defaultValue
+}
+
+func getEnvFloatOrDefault(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+			return parsed
+		}
+		log.Printf("Ignoring invalid number for %s: %q", key, value)
+	}
+	return defaultValue
+}
+
+func getEnvDurationOrDefault(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			return parsed
+		}
+		log.Printf("Ignoring invalid duration for %s: %q", key, value)
+	}
+	return defaultValue
+}
+
+// buildRegistryConfig converts the unknown client settings into a registry configuration
+func buildRegistryConfig(cfg *Config) limiter.RegistryConfig {
+	return limiter.RegistryConfig{
+		UnknownClientPolicy: cfg.UnknownClientPolicy,
+		DefaultTemplate:     cfg.DefaultTemplate,
+		DefaultClientID:     cfg.DefaultClientID,
+		MaxAutoClients:      cfg.MaxAutoClients,
+		IdleTTL:             cfg.AutoClientIdleTTL,
+	}
+}
+
+// buildPoolConfig converts the upstream settings into a pool configuration
+func buildPoolConfig(cfg *Config) (proxy.PoolConfig, error) {
+	poolConfig := proxy.PoolConfig{
+		Name:                "default",
+		Strategy:            proxy.Strategy(cfg.LBStrategy),
+		HealthCheckPath:     cfg.HealthCheckPath,
+		HealthCheckInterval: cfg.HealthCheckInterval,
+		EjectAfter:          cfg.EjectAfter,
+		EjectDuration:       cfg.EjectDuration,
+		Retry: proxy.RetryPolicy{
+			MaxRetries:   cfg.MaxRetries,
+			BaseBackoff:  cfg.RetryBackoff,
+			MaxBackoff:   cfg.RetryMaxBackoff,
+			MaxBodyBytes: cfg.RetryMaxBody,
+		},
+		Breaker: proxy.BreakerConfig{
+			FailureThreshold: cfg.BreakerThreshold,
+			Cooldown:         cfg.BreakerCooldown,
+		},
+	}
+
+	urls := splitList(cfg.UpstreamURL)
+	weights := splitList(cfg.UpstreamWeights)
+	if len(weights) > 0 && len(weights) != len(urls) {
+		return poolConfig, fmt.Errorf("got %d upstream weights for %d upstream URLs", len(weights), len(urls))
+	}
+
+	for i, u := range urls {
+		target := proxy.TargetConfig{URL: u, Weight: 1}
+		if len(weights) > 0 {
+			weight, err := strconv.Atoi(weights[i])
+			if err != nil || weight <= 0 {
+				return poolConfig, fmt.Errorf("invalid weight %q for upstream %s", weights[i], u)
+			}
+			target.Weight = weight
+		}
+		poolConfig.Targets = append(poolConfig.Targets, target)
+	}
+
+	return poolConfig, nil
+}
+
+// splitList splits a comma-separated list, dropping empty entries
+func splitList(value string) []string {
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// parseSampleRates parses a comma-separated list of client=rate pairs
+func parseSampleRates(value string) (map[string]float64, error) {
+	rates := make(map[string]float64)
+	for _, item := range splitList(value) {
+		clientID, rateStr, found := strings.Cut(item, "=")
+		if !found {
+			return nil, fmt.Errorf("expected client=rate, got %q", item)
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(rateStr), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sample rate for %s: %q", clientID, rateStr)
+		}
+		rates[strings.TrimSpace(clientID)] = rate
+	}
+	return rates, nil
+}
+
 func setupDefaultClients(rateLimiter *limiter.Manager) {
 	// Add some example client configurations
 	clients := []struct {
@@ -191,12 +632,16 @@ func setupDefaultClients(rateLimiter *limiter.Manager) {
 	}
 
 	for _, client := range clients {
-		rateLimiter.SetClientConfig(&types.ClientConfig{
+		err := rateLimiter.SetClientConfig(&types.ClientConfig{
 			ClientID: client.clientID,
 			RPM:      client.rpm,
 			TPM:      client.tpm,
 			Enabled:  true,
 		})
+		if err != nil {
+			log.Printf("Failed to add default client %s: %v", client.clientID, err)
+			continue
+		}
 		log.Printf("Added default client: %s (RPM: %v, TPM: %v)", 
 			client.clientID, *client.rpm, *client.tpm)
 	}
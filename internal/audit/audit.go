@@ -0,0 +1,314 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"flowguard/internal/types"
+)
+
+// Sources of configuration changes
+const (
+	SourceREST   = "rest"
+	SourceGRPC   = "grpc"
+	SourceSystem = "system" // Changes made by FlowGuard itself, such as expiring overrides
+)
+
+// Actions recorded in the audit log
+const (
+	ActionCreate   = "create"
+	ActionUpdate   = "update"
+	ActionDelete   = "delete"
+	ActionRollback = "rollback"
+
+	ActionSetOverride    = "set_override"
+	ActionClearOverride  = "clear_override"
+	ActionExpireOverride = "expire_override"
+
+	ActionSetTemplate    = "set_template"
+	ActionDeleteTemplate = "delete_template"
+	ActionSetRule        = "set_rule"
+	ActionDeleteRule     = "delete_rule"
+)
+
+// Event records a single change to a client configuration, limit override,
+// limit template or client rule. Events are chained by hash so that edits to
+// a persisted log are detected.
+type Event struct {
+	ID             int64                `json:"id"`
+	Time           time.Time            `json:"time"`
+	Actor          string               `json:"actor"`
+	Source         string               `json:"source"`
+	RemoteAddr     string               `json:"remote_addr,omitempty"`
+	Action         string               `json:"action"`
+	ClientID       string               `json:"client_id"` // Empty for template and rule changes
+	Before         *types.ClientConfig  `json:"before,omitempty"`
+	After          *types.ClientConfig  `json:"after,omitempty"`
+	Override       *types.LimitOverride `json:"override,omitempty"` // The override set, cleared or expired
+	TemplateBefore *types.LimitTemplate `json:"template_before,omitempty"`
+	TemplateAfter  *types.LimitTemplate `json:"template_after,omitempty"`
+	RuleBefore     *types.ClientRule    `json:"rule_before,omitempty"`
+	RuleAfter      *types.ClientRule    `json:"rule_after,omitempty"`
+	PrevHash       string               `json:"prev_hash"`
+	Hash           string               `json:"hash"`
+}
+
+// Filter selects events from the log
+type Filter struct {
+	ClientID string    // Only events for this client (empty matches all)
+	Since    time.Time // Only events at or after this time (zero matches all)
+	Until    time.Time // Only events before this time (zero matches all)
+	Limit    int       // Return at most this many of the most recent matches (0 returns all)
+}
+
+// errClosed is returned when appending to a closed log
+var errClosed = errors.New("audit log is closed")
+
+// Log is an append-only audit log of configuration changes, kept in memory
+// and optionally persisted to a JSONL file. Events are written to the file by
+// a background goroutine, so appending never waits on disk I/O and can be
+// done while holding the limiter's locks.
+type Log struct {
+	events  []Event
+	file    *os.File
+	pending [][]byte      // Encoded events not yet written to the file, oldest first
+	wake    chan struct{} // Signals the writer that events are pending
+	done    chan struct{}
+	closed  bool
+	mutex   sync.RWMutex
+}
+
+// NewLog creates an audit log. If path is not empty, existing events are
+// loaded from the file, their hash chain verified, and new events appended to it.
+// An incomplete last line, left by a crash during a write, is removed.
+func NewLog(path string) (*Log, error) {
+	l := &Log{}
+	if path == "" {
+		return l, nil
+	}
+
+	complete, err := l.load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	if !complete {
+		// Terminate the last event so the next one starts on its own line
+		if _, err := file.Write([]byte{'\n'}); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to repair audit log: %w", err)
+		}
+	}
+
+	l.file = file
+	l.wake = make(chan struct{}, 1)
+	l.done = make(chan struct{})
+	go l.run()
+	return l, nil
+}
+
+// Append records an event, filling in its ID, time and hashes. The event is
+// visible to queries at once and queued to be written to the file; write
+// failures are logged and retried with the next event.
+func (l *Log) Append(event Event) (Event, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.closed {
+		return event, errClosed
+	}
+
+	event.ID = int64(len(l.events)) + 1
+	event.Time = time.Now().UTC()
+	event.PrevHash = ""
+	if len(l.events) > 0 {
+		event.PrevHash = l.events[len(l.events)-1].Hash
+	}
+	hash, err := hashEvent(event)
+	if err != nil {
+		return event, err
+	}
+	event.Hash = hash
+
+	if l.file != nil {
+		line, err := json.Marshal(event)
+		if err != nil {
+			return event, fmt.Errorf("failed to encode audit event: %w", err)
+		}
+		l.pending = append(l.pending, append(line, '\n'))
+		select {
+		case l.wake <- struct{}{}:
+		default:
+			// The writer has already been woken
+		}
+	}
+
+	l.events = append(l.events, event)
+	return event, nil
+}
+
+// Query returns the events matching a filter, oldest first
+func (l *Log) Query(filter Filter) []Event {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	var result []Event
+	for _, event := range l.events {
+		if filter.ClientID != "" && event.ClientID != filter.ClientID {
+			continue
+		}
+		if !filter.Since.IsZero() && event.Time.Before(filter.Since) {
+			continue
+		}
+		if !filter.Until.IsZero() && !event.Time.Before(filter.Until) {
+			continue
+		}
+		result = append(result, event)
+	}
+
+	if filter.Limit > 0 && len(result) > filter.Limit {
+		result = result[len(result)-filter.Limit:]
+	}
+	return result
+}
+
+// Close stops accepting events, writes the pending ones and closes the file, if any
+func (l *Log) Close() error {
+	l.mutex.Lock()
+	if l.closed || l.file == nil {
+		l.closed = true
+		l.mutex.Unlock()
+		return nil
+	}
+	l.closed = true
+	close(l.wake)
+	l.mutex.Unlock()
+
+	<-l.done
+	return l.file.Close()
+}
+
+// run writes pending events until the log is closed
+func (l *Log) run() {
+	defer close(l.done)
+
+	for range l.wake {
+		l.flush()
+	}
+	l.flush()
+}
+
+// flush writes the pending events to the file. Events that cannot be
+// written stay pending, in order, and are retried on the next flush.
+func (l *Log) flush() {
+	l.mutex.Lock()
+	lines := l.pending
+	l.pending = nil
+	l.mutex.Unlock()
+
+	for i, line := range lines {
+		if _, err := l.file.Write(line); err != nil {
+			log.Printf("Failed to write audit event, %d pending: %v", len(lines)-i, err)
+			l.mutex.Lock()
+			l.pending = append(lines[i:len(lines):len(lines)], l.pending...)
+			l.mutex.Unlock()
+			return
+		}
+	}
+}
+
+// load reads and verifies the events of an existing log file. A last line that
+// is not valid JSON is the remainder of an interrupted write, and is truncated
+// away. It reports whether the file ends with a complete line.
+func (l *Log) load(path string) (bool, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	prevHash := ""
+	offset := int64(0)
+	for line := 1; ; line++ {
+		data, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return false, fmt.Errorf("failed to read audit log: %w", readErr)
+		}
+		last := readErr == io.EOF
+		if last && len(bytes.TrimSpace(data)) == 0 {
+			return len(data) == 0, nil
+		}
+
+		var event Event
+		if err := json.Unmarshal(data, &event); err != nil {
+			if last {
+				log.Printf("Removing incomplete audit log line %d", line)
+				if err := os.Truncate(path, offset); err != nil {
+					return false, fmt.Errorf("failed to repair audit log: %w", err)
+				}
+				return true, nil
+			}
+			return false, fmt.Errorf("audit log line %d is not valid JSON: %w", line, err)
+		}
+		hash, err := hashJSON(data)
+		if err != nil || event.PrevHash != prevHash || event.Hash != hash {
+			return false, fmt.Errorf("audit log line %d fails hash verification", line)
+		}
+		prevHash = event.Hash
+		l.events = append(l.events, event)
+		offset += int64(len(data))
+
+		if last {
+			// A complete event whose newline was not written
+			return false, nil
+		}
+	}
+}
+
+// hashEvent returns the hash of an event's contents, which include the previous hash
+func hashEvent(event Event) (string, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode audit event: %w", err)
+	}
+	return hashJSON(data)
+}
+
+// hashJSON hashes an encoded event without its hash field. The event is
+// re-encoded with sorted keys, so the hash does not depend on field order
+// or on fields added to the event type later.
+func hashJSON(data []byte) (string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return "", err
+	}
+	delete(fields, "hash")
+
+	canonical, err := json.Marshal(fields)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,34 @@
+package capture
+
+// Buffer collects a body up to a size limit, noting whether it was truncated
+type Buffer struct {
+	data      []byte
+	limit     int
+	truncated bool
+}
+
+// NewBuffer creates a buffer that keeps at most limit bytes
+func NewBuffer(limit int) *Buffer {
+	return &Buffer{limit: limit}
+}
+
+// Write implements io.Writer; it never fails so it can be used as a tee
+func (b *Buffer) Write(p []byte) (int, error) {
+	room := b.limit - len(b.data)
+	if len(p) > room {
+		b.truncated = true
+		p = p[:max(room, 0)]
+	}
+	b.data = append(b.data, p...)
+	return len(p), nil
+}
+
+// Bytes returns the collected data
+func (b *Buffer) Bytes() []byte {
+	return b.data
+}
+
+// Truncated reports whether data beyond the limit was discarded
+func (b *Buffer) Truncated() bool {
+	return b.truncated
+}
@@ -0,0 +1,54 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// RedactionRule replaces every match of a regular expression
+type RedactionRule struct {
+	Name        string `json:"name"`
+	Pattern     string `json:"pattern"`
+	Replacement string `json:"replacement,omitempty"` // Defaults to [REDACTED:<name>]
+}
+
+// Config holds the audit capture settings
+type Config struct {
+	Clients                  []string        `json:"clients"`                              // Clients whose traffic is captured
+	Directory                string          `json:"directory"`                            // Directory the JSONL files are written to
+	MaxFileSizeMB            int             `json:"max_file_size_mb,omitempty"`           // Start a new file at this size (default 100)
+	RotateInterval           string          `json:"rotate_interval,omitempty"`            // Start a new file after this long (default 1h)
+	Retention                string          `json:"retention,omitempty"`                  // Delete files older than this (default 720h, "0" keeps all)
+	MaxBodyBytes             int             `json:"max_body_bytes,omitempty"`             // Bodies are truncated to this size (default 1MiB)
+	QueueSize                int             `json:"queue_size,omitempty"`                 // Records buffered for the writer (default 1024)
+	Redactions               []RedactionRule `json:"redactions,omitempty"`                 // Rules applied in addition to the defaults
+	DisableDefaultRedactions bool            `json:"disable_default_redactions,omitempty"` // Skip the built-in email, key and card rules
+}
+
+// DefaultRedactions are applied unless disabled
+var DefaultRedactions = []RedactionRule{
+	{Name: "email", Pattern: `[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`},
+	{Name: "api_key", Pattern: `\b(?:sk|pk|rk)-[A-Za-z0-9_\-]{16,}`},
+	{Name: "bearer_token", Pattern: `(?i)bearer\s+[A-Za-z0-9._\-]{16,}`},
+	{Name: "card_number", Pattern: `\b(?:\d[ \-]?){12,18}\d\b`},
+}
+
+// LoadConfig reads a capture configuration from a JSON file
+func LoadConfig(path string) (Config, error) {
+	var config Config
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("failed to read capture config: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return config, fmt.Errorf("failed to parse capture config: %w", err)
+	}
+
+	return config, nil
+}
@@ -0,0 +1,123 @@
+package capture
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	filePrefix = "capture-"
+	fileSuffix = ".jsonl"
+)
+
+// fileWriter appends lines to timestamped JSONL files, starting a new file
+// when the current one grows too large or too old and deleting files past
+// the retention period. It is only used by the recorder's writer goroutine.
+type fileWriter struct {
+	directory   string
+	maxBytes    int64
+	rotateEvery time.Duration
+	retention   time.Duration
+	file        *os.File
+	size        int64
+	openedAt    time.Time
+}
+
+// newFileWriter creates the capture directory and removes expired files
+func newFileWriter(directory string, maxBytes int64, rotateEvery, retention time.Duration) (*fileWriter, error) {
+	if err := os.MkdirAll(directory, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create capture directory: %w", err)
+	}
+
+	fw := &fileWriter{
+		directory:   directory,
+		maxBytes:    maxBytes,
+		rotateEvery: rotateEvery,
+		retention:   retention,
+	}
+	fw.prune()
+	return fw, nil
+}
+
+// writeLine appends a line, rotating first if needed
+func (fw *fileWriter) writeLine(line []byte) error {
+	if fw.file == nil || fw.shouldRotate(len(line)) {
+		if err := fw.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := fw.file.Write(append(line, '\n'))
+	fw.size += int64(n)
+	return err
+}
+
+// shouldRotate reports whether the current file is full or too old
+func (fw *fileWriter) shouldRotate(next int) bool {
+	if fw.maxBytes > 0 && fw.size > 0 && fw.size+int64(next) > fw.maxBytes {
+		return true
+	}
+	return fw.rotateEvery > 0 && time.Since(fw.openedAt) >= fw.rotateEvery
+}
+
+// rotate closes the current file, opens a new one and prunes expired files
+func (fw *fileWriter) rotate() error {
+	fw.close()
+
+	now := time.Now().UTC()
+	name := filepath.Join(fw.directory, filePrefix+now.Format("20060102T150405.000000000")+fileSuffix)
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to open capture file: %w", err)
+	}
+
+	fw.file = file
+	fw.size = 0
+	fw.openedAt = now
+	fw.prune()
+	return nil
+}
+
+// prune deletes capture files last modified before the retention period
+func (fw *fileWriter) prune() {
+	if fw.retention <= 0 {
+		return
+	}
+
+	entries, err := os.ReadDir(fw.directory)
+	if err != nil {
+		log.Printf("Failed to list capture directory: %v", err)
+		return
+	}
+
+	cutoff := time.Now().Add(-fw.retention)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, filePrefix) || !strings.HasSuffix(name, fileSuffix) {
+			continue
+		}
+		if fw.file != nil && filepath.Join(fw.directory, name) == fw.file.Name() {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(fw.directory, name)); err != nil {
+			log.Printf("Failed to remove expired capture file %s: %v", name, err)
+		}
+	}
+}
+
+// close closes the current file, if any
+func (fw *fileWriter) close() {
+	if fw.file != nil {
+		fw.file.Close()
+		fw.file = nil
+	}
+}
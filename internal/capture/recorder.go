@@ -0,0 +1,276 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Record is a captured request/response exchange
+type Record struct {
+	Time      time.Time `json:"time"`
+	ClientID  string    `json:"client_id"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Status    int       `json:"status"`
+	Model     string    `json:"model,omitempty"`
+	TraceID   string    `json:"trace_id,omitempty"`
+	Request   []byte    `json:"-"`
+	Response  []byte    `json:"-"`
+	Stream    bool      `json:"stream"`
+	Truncated bool      `json:"truncated,omitempty"`
+}
+
+// entry is the JSONL form of a record, written after redaction
+type entry struct {
+	Record
+	Request    json.RawMessage `json:"request,omitempty"`
+	Response   json.RawMessage `json:"response,omitempty"`
+	Completion *string         `json:"completion,omitempty"` // Text reassembled from a streamed response
+}
+
+// redaction is a compiled redaction rule
+type redaction struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// Recorder captures request and response bodies for opted-in clients.
+// Records are queued and redacted and written by a background goroutine, so
+// recording never blocks the proxy; records are dropped if the queue is full.
+type Recorder struct {
+	clients      map[string]bool
+	maxBodyBytes int
+	redactions   []redaction
+	writer       *fileWriter
+	queue        chan Record
+	done         chan struct{}
+	closed       bool
+	mutex        sync.RWMutex
+	recorded     int64
+	dropped      int64
+}
+
+// NewRecorder validates a configuration and starts the writer
+func NewRecorder(config Config) (*Recorder, error) {
+	if config.Directory == "" {
+		return nil, fmt.Errorf("capture directory is required")
+	}
+
+	rotateEvery, err := parseDuration(config.RotateInterval, time.Hour)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rotate_interval: %w", err)
+	}
+	retention, err := parseDuration(config.Retention, 30*24*time.Hour)
+	if err != nil {
+		return nil, fmt.Errorf("invalid retention: %w", err)
+	}
+
+	maxFileSizeMB := config.MaxFileSizeMB
+	if maxFileSizeMB <= 0 {
+		maxFileSizeMB = 100
+	}
+	maxBodyBytes := config.MaxBodyBytes
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = 1 << 20
+	}
+	queueSize := config.QueueSize
+	if queueSize <= 0 {
+		queueSize = 1024
+	}
+
+	rules := config.Redactions
+	if !config.DisableDefaultRedactions {
+		rules = append(append([]RedactionRule{}, DefaultRedactions...), rules...)
+	}
+	var redactions []redaction
+	for _, rule := range rules {
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %s: %w", rule.Name, err)
+		}
+		replacement := rule.Replacement
+		if replacement == "" {
+			replacement = "[REDACTED:" + rule.Name + "]"
+		}
+		redactions = append(redactions, redaction{pattern: pattern, replacement: replacement})
+	}
+
+	writer, err := newFileWriter(config.Directory, int64(maxFileSizeMB)<<20, rotateEvery, retention)
+	if err != nil {
+		return nil, err
+	}
+
+	clients := make(map[string]bool, len(config.Clients))
+	for _, clientID := range config.Clients {
+		clients[clientID] = true
+	}
+
+	r := &Recorder{
+		clients:      clients,
+		maxBodyBytes: maxBodyBytes,
+		redactions:   redactions,
+		writer:       writer,
+		queue:        make(chan Record, queueSize),
+		done:         make(chan struct{}),
+	}
+	go r.run()
+	return r, nil
+}
+
+// Enabled reports whether a client's traffic is captured
+func (r *Recorder) Enabled(clientID string) bool {
+	return r.clients[clientID]
+}
+
+// MaxBodyBytes returns the size bodies are truncated to
+func (r *Recorder) MaxBodyBytes() int {
+	return r.maxBodyBytes
+}
+
+// Record queues a record for writing without blocking
+func (r *Recorder) Record(record Record) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if r.closed {
+		return
+	}
+
+	select {
+	case r.queue <- record:
+	default:
+		if atomic.AddInt64(&r.dropped, 1)%100 == 1 {
+			log.Printf("Audit capture queue is full, dropping records")
+		}
+	}
+}
+
+// Recorded returns the number of records written
+func (r *Recorder) Recorded() int64 {
+	return atomic.LoadInt64(&r.recorded)
+}
+
+// Dropped returns the number of records dropped because the queue was full
+func (r *Recorder) Dropped() int64 {
+	return atomic.LoadInt64(&r.dropped)
+}
+
+// Close stops accepting records, writes the queued ones and closes the file
+func (r *Recorder) Close() {
+	r.mutex.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.queue)
+	}
+	r.mutex.Unlock()
+	<-r.done
+}
+
+// run writes queued records until the recorder is closed
+func (r *Recorder) run() {
+	defer close(r.done)
+	defer r.writer.close()
+
+	for record := range r.queue {
+		line, err := json.Marshal(r.prepare(record))
+		if err != nil {
+			log.Printf("Failed to encode audit capture record: %v", err)
+			continue
+		}
+		if err := r.writer.writeLine(line); err != nil {
+			log.Printf("Failed to write audit capture record: %v", err)
+			continue
+		}
+		atomic.AddInt64(&r.recorded, 1)
+	}
+}
+
+// prepare redacts a record's bodies and reassembles streamed completions
+func (r *Recorder) prepare(record Record) entry {
+	e := entry{
+		Record:  record,
+		Request: r.encodeBody(record.Request),
+	}
+
+	// Streamed responses are stored as the reassembled text only, since
+	// sensitive values split across chunks would escape redaction
+	if record.Stream {
+		completion := r.redact(reassembleStream(record.Response))
+		e.Completion = &completion
+	} else {
+		e.Response = r.encodeBody(record.Response)
+	}
+	return e
+}
+
+// encodeBody redacts a body and embeds it as JSON, or as a JSON string if it is not JSON
+func (r *Recorder) encodeBody(body []byte) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+
+	redacted := r.redact(string(body))
+	if json.Valid([]byte(redacted)) {
+		var compact bytes.Buffer
+		if json.Compact(&compact, []byte(redacted)) == nil {
+			return compact.Bytes()
+		}
+	}
+
+	encoded, _ := json.Marshal(redacted)
+	return encoded
+}
+
+// redact applies the redaction rules in order
+func (r *Recorder) redact(text string) string {
+	for _, rule := range r.redactions {
+		text = rule.pattern.ReplaceAllString(text, rule.replacement)
+	}
+	return text
+}
+
+// reassembleStream concatenates the text deltas of an SSE response, in either
+// the OpenAI chunk format or Anthropic's content_block_delta events
+func reassembleStream(body []byte) string {
+	var text strings.Builder
+	for _, line := range bytes.Split(body, []byte("\n")) {
+		payload, found := bytes.CutPrefix(bytes.TrimSpace(line), []byte("data:"))
+		if !found {
+			continue
+		}
+
+		var event struct {
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+			} `json:"choices"`
+			Delta struct {
+				Text string `json:"text"`
+			} `json:"delta"`
+		}
+		if json.Unmarshal(bytes.TrimSpace(payload), &event) != nil {
+			continue
+		}
+
+		for _, choice := range event.Choices {
+			text.WriteString(choice.Delta.Content)
+		}
+		text.WriteString(event.Delta.Text)
+	}
+	return text.String()
+}
+
+// parseDuration parses an optional duration, using a default when empty
+func parseDuration(value string, defaultValue time.Duration) (time.Duration, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	return time.ParseDuration(value)
+}
@@ -0,0 +1,198 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"flowguard/internal/audit"
+	"flowguard/internal/limiter"
+	"flowguard/internal/types"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// ActorHeader identifies the operator making a change through the REST API.
+// gRPC callers send the same value in the x-actor metadata key.
+const ActorHeader = "X-Actor"
+
+// actorMetadataKey identifies the operator making a change through the gRPC API
+const actorMetadataKey = "x-actor"
+
+// unknownActor is recorded when a caller does not identify itself
+const unknownActor = "unknown"
+
+// restActor returns the actor and remote address of a REST request
+func restActor(r *http.Request) (string, string) {
+	actor := r.Header.Get(ActorHeader)
+	if actor == "" {
+		actor = unknownActor
+	}
+	return actor, r.RemoteAddr
+}
+
+// grpcActor returns the actor and remote address of a gRPC call
+func grpcActor(ctx context.Context) (string, string) {
+	actor := unknownActor
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(actorMetadataKey); len(values) > 0 && values[0] != "" {
+			actor = values[0]
+		}
+	}
+
+	remoteAddr := ""
+	if p, ok := peer.FromContext(ctx); ok {
+		remoteAddr = p.Addr.String()
+	}
+	return actor, remoteAddr
+}
+
+// changeAction returns the audit action for replacing before with after
+func changeAction(before, after *types.ClientConfig) string {
+	switch {
+	case after == nil:
+		return audit.ActionDelete
+	case before == nil:
+		return audit.ActionCreate
+	}
+	return audit.ActionUpdate
+}
+
+// errAuditFailed marks changes rejected because they could not be audited
+var errAuditFailed = errors.New("failed to record audit event")
+
+// auditHook returns a change hook that appends the change to the audit log, if
+// one is set. Changes are recorded before they are applied, so a change that
+// cannot be audited is rejected; the log writes the event to disk afterwards,
+// without holding up the limiter. If action is empty it is derived from the change.
+func auditHook(auditLog *audit.Log, action, actor, source, remoteAddr string) limiter.ChangeHook {
+	if auditLog == nil {
+		return nil
+	}
+
+	return func(before, after *types.ClientConfig) error {
+		event := audit.Event{
+			Actor:      actor,
+			Source:     source,
+			RemoteAddr: remoteAddr,
+			Action:     action,
+			Before:     before.Clone(),
+			After:      after.Clone(),
+		}
+		if event.Action == "" {
+			event.Action = changeAction(before, after)
+		}
+		if before != nil {
+			event.ClientID = before.ClientID
+		} else {
+			event.ClientID = after.ClientID
+		}
+
+		if _, err := auditLog.Append(event); err != nil {
+			return fmt.Errorf("%w: %v", errAuditFailed, err)
+		}
+		return nil
+	}
+}
+
+// overrideAuditHook returns an override hook that appends the change to the
+// audit log, if one is set. Like configuration changes, an override change
+// that cannot be audited is rejected.
+func overrideAuditHook(auditLog *audit.Log, actor, source, remoteAddr string) limiter.OverrideHook {
+	if auditLog == nil {
+		return nil
+	}
+
+	return func(clientID string, before, after *types.LimitOverride) error {
+		event := audit.Event{
+			Actor:      actor,
+			Source:     source,
+			RemoteAddr: remoteAddr,
+			Action:     audit.ActionSetOverride,
+			ClientID:   clientID,
+			Override:   after.Clone(),
+		}
+		if after == nil {
+			event.Action = audit.ActionClearOverride
+			event.Override = before.Clone()
+		}
+
+		if _, err := auditLog.Append(event); err != nil {
+			return fmt.Errorf("%w: %v", errAuditFailed, err)
+		}
+		return nil
+	}
+}
+
+// templateAuditHook returns a template hook that appends the change to the
+// audit log, if one is set. Like configuration changes, a template change
+// that cannot be audited is rejected.
+func templateAuditHook(auditLog *audit.Log, actor, source, remoteAddr string) limiter.TemplateHook {
+	if auditLog == nil {
+		return nil
+	}
+
+	return func(before, after *types.LimitTemplate) error {
+		event := audit.Event{
+			Actor:          actor,
+			Source:         source,
+			RemoteAddr:     remoteAddr,
+			Action:         audit.ActionSetTemplate,
+			TemplateBefore: before.Clone(),
+			TemplateAfter:  after.Clone(),
+		}
+		if after == nil {
+			event.Action = audit.ActionDeleteTemplate
+		}
+
+		if _, err := auditLog.Append(event); err != nil {
+			return fmt.Errorf("%w: %v", errAuditFailed, err)
+		}
+		return nil
+	}
+}
+
+// ruleAuditHook returns a rule hook that appends the change to the audit log,
+// if one is set. Like configuration changes, a rule change that cannot be
+// audited is rejected.
+func ruleAuditHook(auditLog *audit.Log, actor, source, remoteAddr string) limiter.RuleHook {
+	if auditLog == nil {
+		return nil
+	}
+
+	return func(before, after *types.ClientRule) error {
+		event := audit.Event{
+			Actor:      actor,
+			Source:     source,
+			RemoteAddr: remoteAddr,
+			Action:     audit.ActionSetRule,
+			RuleBefore: before.Clone(),
+			RuleAfter:  after.Clone(),
+		}
+		if after == nil {
+			event.Action = audit.ActionDeleteRule
+		}
+
+		if _, err := auditLog.Append(event); err != nil {
+			return fmt.Errorf("%w: %v", errAuditFailed, err)
+		}
+		return nil
+	}
+}
+
+// OverrideExpiryAuditHook returns a hook for limiter.Manager.SetOverrideExpiryHook
+// that records expired overrides in the audit log
+func OverrideExpiryAuditHook(auditLog *audit.Log) limiter.OverrideHook {
+	return func(clientID string, before, _ *types.LimitOverride) error {
+		_, err := auditLog.Append(audit.Event{
+			Actor:    audit.SourceSystem,
+			Source:   audit.SourceSystem,
+			Action:   audit.ActionExpireOverride,
+			ClientID: clientID,
+			Override: before.Clone(),
+		})
+		return err
+	}
+}
@@ -2,22 +2,36 @@ package config
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"net"
+	"time"
 
+	"flowguard/internal/audit"
 	"flowguard/internal/limiter"
 	pb "flowguard/internal/proto"
+	pbv2 "flowguard/internal/proto/v2"
+	"flowguard/internal/proxy"
 	"flowguard/internal/types"
 
+	rlsv3 "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
 )
 
-// GRPCServer implements the FlowGuard gRPC service
+// GRPCServer implements the FlowGuard gRPC service. v1 reports failures in
+// Success/Found fields; v2, registered alongside it, uses status codes.
 type GRPCServer struct {
 	pb.UnimplementedFlowGuardServiceServer
 	rateLimiter *limiter.Manager
+	upstreams   []*proxy.Pool
+	auditLog    *audit.Log
 	server      *grpc.Server
+	stopping    chan struct{} // Closed on Stop to end open streams
 }
 
 // NewGRPCServer creates a new gRPC server
@@ -25,9 +39,12 @@ func NewGRPCServer(rateLimiter *limiter.Manager) *GRPCServer {
 	s := &GRPCServer{
 		rateLimiter: rateLimiter,
 		server:      grpc.NewServer(),
+		stopping:    make(chan struct{}),
 	}
 
 	pb.RegisterFlowGuardServiceServer(s.server, s)
+	pbv2.RegisterFlowGuardServiceServer(s.server, &grpcServerV2{server: s})
+	rlsv3.RegisterRateLimitServiceServer(s.server, &rateLimitService{rateLimiter: rateLimiter})
 	
 	// Enable reflection for debugging with tools like grpcurl
 	reflection.Register(s.server)
@@ -35,6 +52,16 @@ func NewGRPCServer(rateLimiter *limiter.Manager) *GRPCServer {
 	return s
 }
 
+// RegisterUpstream exposes an upstream pool through the admin API
+func (s *GRPCServer) RegisterUpstream(pool *proxy.Pool) {
+	s.upstreams = append(s.upstreams, pool)
+}
+
+// SetAuditLog records configuration changes made through the API in an audit log
+func (s *GRPCServer) SetAuditLog(auditLog *audit.Log) {
+	s.auditLog = auditLog
+}
+
 // Start starts the gRPC server on the specified address
 func (s *GRPCServer) Start(address string) error {
 	listener, err := net.Listen("tcp", address)
@@ -45,8 +72,9 @@ func (s *GRPCServer) Start(address string) error {
 	return s.server.Serve(listener)
 }
 
-// Stop gracefully stops the gRPC server
+// Stop gracefully stops the gRPC server, ending any open watch streams
 func (s *GRPCServer) Stop() {
+	close(s.stopping)
 	s.server.GracefulStop()
 }
 
@@ -59,19 +87,57 @@ func (s *GRPCServer) SetClientConfig(ctx context.Context, req *pb.SetClientConfi
 		}, nil
 	}
 
-	if req.Config.ClientId == "" {
+	config := protoToClientConfig(req.Config)
+	stored, err := s.rateLimiter.UpdateClient(config.ClientID, req.ExpectedVersion, func(*types.ClientConfig) (*types.ClientConfig, error) {
+		return config, nil
+	}, s.auditHook(ctx, ""))
+	if err != nil {
+		if invalid := invalidArgument(err); invalid != nil {
+			return nil, invalid
+		}
 		return &pb.SetClientConfigResponse{
+			Success: false,
+			Message: changeErrorMessage(config.ClientID, err),
+		}, nil
+	}
+
+	return &pb.SetClientConfigResponse{
+		Success: true,
+		Message: "Client configuration updated successfully",
+		Config:  clientConfigToProto(stored),
+	}, nil
+}
+
+// UpdateClientConfig changes the fields of a client's configuration named in a field mask
+func (s *GRPCServer) UpdateClientConfig(ctx context.Context, req *pb.UpdateClientConfigRequest) (*pb.UpdateClientConfigResponse, error) {
+	if req.Config == nil || req.Config.ClientId == "" {
+		return &pb.UpdateClientConfigResponse{
 			Success: false,
 			Message: "Client ID is required",
 		}, nil
 	}
 
-	config := protoToClientConfig(req.Config)
-	s.rateLimiter.SetClientConfig(config)
+	clientID := req.Config.ClientId
+	stored, err := s.rateLimiter.UpdateClient(clientID, req.ExpectedVersion, func(current *types.ClientConfig) (*types.ClientConfig, error) {
+		if current == nil {
+			return nil, types.ErrClientNotFound
+		}
+		return applyFieldMask(current, req.Config, req.UpdateMask)
+	}, s.auditHook(ctx, ""))
+	if err != nil {
+		if invalid := invalidArgument(err); invalid != nil {
+			return nil, invalid
+		}
+		return &pb.UpdateClientConfigResponse{
+			Success: false,
+			Message: changeErrorMessage(clientID, err),
+		}, nil
+	}
 
-	return &pb.SetClientConfigResponse{
+	return &pb.UpdateClientConfigResponse{
 		Success: true,
 		Message: "Client configuration updated successfully",
+		Config:  clientConfigToProto(stored),
 	}, nil
 }
 
@@ -90,9 +156,13 @@ func (s *GRPCServer) GetClientConfig(ctx context.Context, req *pb.GetClientConfi
 		}, nil
 	}
 
+	effective, _ := s.rateLimiter.GetEffectiveLimits(req.ClientId)
+	override, _ := s.rateLimiter.GetOverride(req.ClientId)
 	return &pb.GetClientConfigResponse{
-		Config: clientConfigToProto(config),
-		Found:  true,
+		Config:    clientConfigToProto(config),
+		Found:     true,
+		Effective: effectiveLimitsToProto(effective),
+		Override:  limitOverrideToProto(override),
 	}, nil
 }
 
@@ -117,25 +187,46 @@ func (s *GRPCServer) GetClientStats(ctx context.Context, req *pb.GetClientStatsR
 	}, nil
 }
 
-// ListClients lists all configured clients
+// ListClients lists a page of the configured clients matching the request's filters
 func (s *GRPCServer) ListClients(ctx context.Context, req *pb.ListClientsRequest) (*pb.ListClientsResponse, error) {
-	configs := s.rateLimiter.GetAllClients()
-	stats := s.rateLimiter.GetAllStats()
+	query := limiter.ClientQuery{
+		Enabled:    req.Enabled,
+		HasLimits:  req.HasLimits,
+		IDPrefix:   req.IdPrefix,
+		SortBy:     req.SortBy,
+		Descending: req.Descending,
+		PageSize:   int(req.PageSize),
+		PageToken:  req.PageToken,
+	}
+	if req.SeenAfter > 0 {
+		query.SeenAfter = time.Unix(req.SeenAfter, 0)
+	}
+	if req.SeenBefore > 0 {
+		query.SeenBefore = time.Unix(req.SeenBefore, 0)
+	}
+
+	page, err := s.rateLimiter.ListClients(query)
+	var invalid *types.ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		return nil, badRequest(invalid)
+	case err != nil:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
 
 	var protoConfigs []*pb.ClientConfig
 	var protoStats []*pb.ClientStats
 
-	for _, config := range configs {
-		protoConfigs = append(protoConfigs, clientConfigToProto(config))
-	}
-
-	for _, stat := range stats {
-		protoStats = append(protoStats, clientStatsToProto(stat))
+	for _, client := range page.Clients {
+		protoConfigs = append(protoConfigs, clientConfigToProto(client.Config))
+		protoStats = append(protoStats, clientStatsToProto(client.Stats))
 	}
 
 	return &pb.ListClientsResponse{
-		Clients: protoConfigs,
-		Stats:   protoStats,
+		Clients:       protoConfigs,
+		Stats:         protoStats,
+		NextPageToken: page.NextPageToken,
+		Total:         int32(page.Total),
 	}, nil
 }
 
@@ -148,11 +239,13 @@ func (s *GRPCServer) DeleteClient(ctx context.Context, req *pb.DeleteClientReque
 		}, nil
 	}
 
-	deleted := s.rateLimiter.DeleteClient(req.ClientId)
-	if !deleted {
+	_, err := s.rateLimiter.UpdateClient(req.ClientId, 0, func(*types.ClientConfig) (*types.ClientConfig, error) {
+		return nil, nil
+	}, s.auditHook(ctx, ""))
+	if err != nil {
 		return &pb.DeleteClientResponse{
 			Success: false,
-			Message: "Client not found",
+			Message: changeErrorMessage(req.ClientId, err),
 		}, nil
 	}
 
@@ -162,12 +255,533 @@ func (s *GRPCServer) DeleteClient(ctx context.Context, req *pb.DeleteClientReque
 	}, nil
 }
 
+// ListUpstreams lists upstream pools with their circuit breaker state and target health
+func (s *GRPCServer) ListUpstreams(ctx context.Context, req *pb.ListUpstreamsRequest) (*pb.ListUpstreamsResponse, error) {
+	var upstreams []*pb.UpstreamStatus
+	for _, pool := range s.upstreams {
+		upstreams = append(upstreams, upstreamStatusToProto(pool.Status()))
+	}
+
+	return &pb.ListUpstreamsResponse{
+		Upstreams: upstreams,
+	}, nil
+}
+
+// ListAuditEvents lists configuration changes, filtered by client and time range
+func (s *GRPCServer) ListAuditEvents(ctx context.Context, req *pb.ListAuditEventsRequest) (*pb.ListAuditEventsResponse, error) {
+	if s.auditLog == nil {
+		return &pb.ListAuditEventsResponse{}, nil
+	}
+
+	filter := audit.Filter{
+		ClientID: req.ClientId,
+		Limit:    int(req.Limit),
+	}
+	if req.Since > 0 {
+		filter.Since = time.Unix(req.Since, 0)
+	}
+	if req.Until > 0 {
+		filter.Until = time.Unix(req.Until, 0)
+	}
+
+	var events []*pb.AuditEvent
+	for _, event := range s.auditLog.Query(filter) {
+		events = append(events, auditEventToProto(event))
+	}
+
+	return &pb.ListAuditEventsResponse{
+		Events: events,
+	}, nil
+}
+
+// GetClientHistory lists the retained versions of a client's configuration
+func (s *GRPCServer) GetClientHistory(ctx context.Context, req *pb.GetClientHistoryRequest) (*pb.GetClientHistoryResponse, error) {
+	history, exists := s.rateLimiter.GetClientHistory(req.ClientId)
+	if !exists {
+		return &pb.GetClientHistoryResponse{
+			Found: false,
+		}, nil
+	}
+
+	var versions []*pb.ClientConfig
+	for _, config := range history {
+		versions = append(versions, clientConfigToProto(config))
+	}
+
+	return &pb.GetClientHistoryResponse{
+		Versions: versions,
+		Found:    true,
+	}, nil
+}
+
+// RollbackClientConfig restores a previous version of a client's configuration
+func (s *GRPCServer) RollbackClientConfig(ctx context.Context, req *pb.RollbackClientConfigRequest) (*pb.RollbackClientConfigResponse, error) {
+	if req.ClientId == "" {
+		return &pb.RollbackClientConfigResponse{
+			Success: false,
+			Message: "Client ID is required",
+		}, nil
+	}
+
+	config, err := s.rateLimiter.RollbackClient(req.ClientId, req.Version, req.ExpectedVersion, s.auditHook(ctx, audit.ActionRollback))
+	if err != nil {
+		if invalid := invalidArgument(err); invalid != nil {
+			return nil, invalid
+		}
+		return &pb.RollbackClientConfigResponse{
+			Success: false,
+			Message: changeErrorMessage(req.ClientId, err),
+		}, nil
+	}
+
+	return &pb.RollbackClientConfigResponse{
+		Success: true,
+		Message: fmt.Sprintf("Client configuration rolled back to version %d", req.Version),
+		Config:  clientConfigToProto(config),
+	}, nil
+}
+
+// CheckQuota reports whether a client's quota allows a call, without consuming it
+func (s *GRPCServer) CheckQuota(ctx context.Context, req *pb.QuotaRequest) (*pb.QuotaResponse, error) {
+	requests, err := quotaRequestAmounts(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return quotaToProto(s.rateLimiter.CheckQuota(req.ClientId, requests, req.Tokens)), nil
+}
+
+// ConsumeQuota consumes part of a client's quota for a call made outside the proxy
+func (s *GRPCServer) ConsumeQuota(ctx context.Context, req *pb.QuotaRequest) (*pb.QuotaResponse, error) {
+	requests, err := quotaRequestAmounts(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return quotaToProto(s.rateLimiter.ConsumeQuota(req.ClientId, requests, req.Tokens)), nil
+}
+
+// RefundQuota returns consumed quota that was not used
+func (s *GRPCServer) RefundQuota(ctx context.Context, req *pb.QuotaRequest) (*pb.RefundQuotaResponse, error) {
+	requests, err := quotaRequestAmounts(req)
+	if err != nil {
+		return nil, err
+	}
+
+	limits, err := s.rateLimiter.RefundQuota(req.ClientId, requests, req.Tokens)
+	if err != nil {
+		return &pb.RefundQuotaResponse{
+			Success: false,
+			Message: "Client not found",
+		}, nil
+	}
+
+	return &pb.RefundQuotaResponse{
+		Success: true,
+		Message: "Quota refunded successfully",
+		Limits:  limitStatusesToProto(limits),
+	}, nil
+}
+
+// WatchClientConfigs streams the current client configurations, then every change to them
+func (s *GRPCServer) WatchClientConfigs(req *pb.WatchClientConfigsRequest, stream grpc.ServerStreamingServer[pb.ClientConfigEvent]) error {
+	configs, watch := s.rateLimiter.WatchConfigs(req.ClientId)
+	defer watch.Close()
+
+	for _, event := range snapshotEvents(configs) {
+		if err := stream.Send(configEventToProto(event, true)); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case event, ok := <-watch.Events:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "watch fell too far behind configuration changes")
+			}
+			if err := stream.Send(configEventToProto(event, false)); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case <-s.stopping:
+			return status.Error(codes.Unavailable, "server is shutting down")
+		}
+	}
+}
+
+// StreamClientStats periodically streams the change in client statistics
+func (s *GRPCServer) StreamClientStats(req *pb.StreamClientStatsRequest, stream grpc.ServerStreamingServer[pb.ClientStatsUpdate]) error {
+	interval, err := statsInterval("interval_ms", time.Duration(req.IntervalMs)*time.Millisecond)
+	var invalid *types.ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		return badRequest(invalid)
+	case err != nil:
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+
+	updates := s.rateLimiter.WatchStats(ctx, req.ClientId, interval)
+	for {
+		select {
+		case deltas, ok := <-updates:
+			if !ok {
+				return stream.Context().Err()
+			}
+			if err := stream.Send(statsUpdateToProto(deltas)); err != nil {
+				return err
+			}
+		case <-s.stopping:
+			return status.Error(codes.Unavailable, "server is shutting down")
+		}
+	}
+}
+
+// SetLimitTemplate creates or replaces a limit template, updating the limits of clients that use it
+func (s *GRPCServer) SetLimitTemplate(ctx context.Context, req *pb.SetLimitTemplateRequest) (*pb.SetLimitTemplateResponse, error) {
+	if req.Template == nil {
+		return &pb.SetLimitTemplateResponse{
+			Success: false,
+			Message: "Template is required",
+		}, nil
+	}
+
+	actor, remoteAddr := grpcActor(ctx)
+	stored, updated, err := s.rateLimiter.SetTemplate(protoToLimitTemplate(req.Template), templateAuditHook(s.auditLog, actor, audit.SourceGRPC, remoteAddr))
+	if errors.Is(err, errAuditFailed) {
+		return &pb.SetLimitTemplateResponse{
+			Success: false,
+			Message: changeErrorMessage(req.Template.Name, err),
+		}, nil
+	}
+	if err != nil {
+		return nil, invalidMessage("template", err)
+	}
+
+	return &pb.SetLimitTemplateResponse{
+		Success:        true,
+		Message:        fmt.Sprintf("Template saved; limits of %d clients updated", updated),
+		Template:       limitTemplateToProto(stored),
+		UpdatedClients: int32(updated),
+	}, nil
+}
+
+// GetLimitTemplate retrieves a limit template
+func (s *GRPCServer) GetLimitTemplate(ctx context.Context, req *pb.GetLimitTemplateRequest) (*pb.GetLimitTemplateResponse, error) {
+	template, exists := s.rateLimiter.GetTemplate(req.Name)
+	if !exists {
+		return &pb.GetLimitTemplateResponse{
+			Found: false,
+		}, nil
+	}
+
+	return &pb.GetLimitTemplateResponse{
+		Template: limitTemplateToProto(template),
+		Found:    true,
+	}, nil
+}
+
+// ListLimitTemplates lists all limit templates
+func (s *GRPCServer) ListLimitTemplates(ctx context.Context, req *pb.ListLimitTemplatesRequest) (*pb.ListLimitTemplatesResponse, error) {
+	var templates []*pb.LimitTemplate
+	for _, template := range s.rateLimiter.ListTemplates() {
+		templates = append(templates, limitTemplateToProto(template))
+	}
+
+	return &pb.ListLimitTemplatesResponse{
+		Templates: templates,
+	}, nil
+}
+
+// DeleteLimitTemplate removes a limit template that no client uses
+func (s *GRPCServer) DeleteLimitTemplate(ctx context.Context, req *pb.DeleteLimitTemplateRequest) (*pb.DeleteLimitTemplateResponse, error) {
+	actor, remoteAddr := grpcActor(ctx)
+	if err := s.rateLimiter.DeleteTemplate(req.Name, templateAuditHook(s.auditLog, actor, audit.SourceGRPC, remoteAddr)); err != nil {
+		var inUse *limiter.TemplateInUseError
+		message := "Template not found"
+		switch {
+		case errors.As(err, &inUse):
+			message = "Template is in use: " + inUse.Error()
+		case !errors.Is(err, limiter.ErrTemplateNotFound):
+			message = changeErrorMessage(req.Name, err)
+		}
+		return &pb.DeleteLimitTemplateResponse{
+			Success: false,
+			Message: message,
+		}, nil
+	}
+
+	return &pb.DeleteLimitTemplateResponse{
+		Success: true,
+		Message: "Template deleted successfully",
+	}, nil
+}
+
+// SetClientRule creates or replaces a rule setting the limits of unconfigured clients whose IDs match a pattern
+func (s *GRPCServer) SetClientRule(ctx context.Context, req *pb.SetClientRuleRequest) (*pb.SetClientRuleResponse, error) {
+	if req.Rule == nil {
+		return &pb.SetClientRuleResponse{
+			Success: false,
+			Message: "Rule is required",
+		}, nil
+	}
+
+	actor, remoteAddr := grpcActor(ctx)
+	stored, err := s.rateLimiter.SetRule(protoToClientRule(req.Rule), ruleAuditHook(s.auditLog, actor, audit.SourceGRPC, remoteAddr))
+	if errors.Is(err, errAuditFailed) {
+		return &pb.SetClientRuleResponse{
+			Success: false,
+			Message: changeErrorMessage(req.Rule.Name, err),
+		}, nil
+	}
+	if err != nil {
+		return nil, invalidMessage("rule", err)
+	}
+
+	return &pb.SetClientRuleResponse{
+		Success: true,
+		Message: "Client rule saved successfully",
+		Rule:    clientRuleToProto(stored),
+	}, nil
+}
+
+// GetClientRule retrieves a client rule
+func (s *GRPCServer) GetClientRule(ctx context.Context, req *pb.GetClientRuleRequest) (*pb.GetClientRuleResponse, error) {
+	rule, exists := s.rateLimiter.GetRule(req.Name)
+	if !exists {
+		return &pb.GetClientRuleResponse{
+			Found: false,
+		}, nil
+	}
+
+	return &pb.GetClientRuleResponse{
+		Rule:  clientRuleToProto(rule),
+		Found: true,
+	}, nil
+}
+
+// ListClientRules lists all client rules in the order they are evaluated
+func (s *GRPCServer) ListClientRules(ctx context.Context, req *pb.ListClientRulesRequest) (*pb.ListClientRulesResponse, error) {
+	var rules []*pb.ClientRule
+	for _, rule := range s.rateLimiter.ListRules() {
+		rules = append(rules, clientRuleToProto(rule))
+	}
+
+	return &pb.ListClientRulesResponse{
+		Rules: rules,
+	}, nil
+}
+
+// DeleteClientRule removes a client rule and evicts the clients created for it
+func (s *GRPCServer) DeleteClientRule(ctx context.Context, req *pb.DeleteClientRuleRequest) (*pb.DeleteClientRuleResponse, error) {
+	actor, remoteAddr := grpcActor(ctx)
+	if err := s.rateLimiter.DeleteRule(req.Name, ruleAuditHook(s.auditLog, actor, audit.SourceGRPC, remoteAddr)); err != nil {
+		message := "Client rule not found"
+		if !errors.Is(err, limiter.ErrRuleNotFound) {
+			message = changeErrorMessage(req.Name, err)
+		}
+		return &pb.DeleteClientRuleResponse{
+			Success: false,
+			Message: message,
+		}, nil
+	}
+
+	return &pb.DeleteClientRuleResponse{
+		Success: true,
+		Message: "Client rule deleted successfully",
+	}, nil
+}
+
+// quotaRequestAmounts validates a quota request and returns its request
+// count, which defaults to 1
+func quotaRequestAmounts(req *pb.QuotaRequest) (int64, error) {
+	requests := int64(1)
+	if req.Requests != nil {
+		requests = *req.Requests
+	}
+
+	err := validateQuota(req.ClientId, requests, req.Tokens)
+	var invalid *types.ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		return 0, badRequest(invalid)
+	case err != nil:
+		return 0, status.Error(codes.Internal, err.Error())
+	}
+	return requests, nil
+}
+
+// SetClientOverride temporarily changes a client's limits, replacing any override it has
+func (s *GRPCServer) SetClientOverride(ctx context.Context, req *pb.SetClientOverrideRequest) (*pb.SetClientOverrideResponse, error) {
+	if req.Override == nil {
+		return &pb.SetClientOverrideResponse{
+			Success: false,
+			Message: "Override is required",
+		}, nil
+	}
+
+	actor, remoteAddr := grpcActor(ctx)
+	override := protoToLimitOverride(req.Override)
+	override.Actor = actor
+	ttl := time.Duration(req.TtlSeconds) * time.Second
+	stored, err := s.rateLimiter.SetOverride(req.ClientId, override, ttl, overrideAuditHook(s.auditLog, actor, audit.SourceGRPC, remoteAddr))
+	if err != nil {
+		if invalid := invalidOverride(err); invalid != nil {
+			return nil, invalid
+		}
+		return &pb.SetClientOverrideResponse{
+			Success: false,
+			Message: changeErrorMessage(req.ClientId, err),
+		}, nil
+	}
+
+	effective, _ := s.rateLimiter.GetEffectiveLimits(req.ClientId)
+	return &pb.SetClientOverrideResponse{
+		Success:   true,
+		Message:   "Limit override applied until " + stored.ExpiresAt.Format(time.RFC3339),
+		Override:  limitOverrideToProto(stored),
+		Effective: effectiveLimitsToProto(effective),
+	}, nil
+}
+
+// ClearClientOverride removes a client's limit override before it expires
+func (s *GRPCServer) ClearClientOverride(ctx context.Context, req *pb.ClearClientOverrideRequest) (*pb.ClearClientOverrideResponse, error) {
+	actor, remoteAddr := grpcActor(ctx)
+	if err := s.rateLimiter.ClearOverride(req.ClientId, overrideAuditHook(s.auditLog, actor, audit.SourceGRPC, remoteAddr)); err != nil {
+		message := "Client has no limit override"
+		if !errors.Is(err, limiter.ErrOverrideNotFound) {
+			message = changeErrorMessage(req.ClientId, err)
+		}
+		return &pb.ClearClientOverrideResponse{
+			Success: false,
+			Message: message,
+		}, nil
+	}
+
+	return &pb.ClearClientOverrideResponse{
+		Success: true,
+		Message: "Limit override removed",
+	}, nil
+}
+
+// auditHook audits changes made by a gRPC call
+func (s *GRPCServer) auditHook(ctx context.Context, action string) limiter.ChangeHook {
+	actor, remoteAddr := grpcActor(ctx)
+	return auditHook(s.auditLog, action, actor, audit.SourceGRPC, remoteAddr)
+}
+
+// ValidateClientConfig checks a client configuration without applying it
+func (s *GRPCServer) ValidateClientConfig(ctx context.Context, req *pb.ValidateClientConfigRequest) (*pb.ValidateClientConfigResponse, error) {
+	if req.Config == nil {
+		return nil, status.Error(codes.InvalidArgument, "config is required")
+	}
+
+	if invalid := invalidArgument(protoToClientConfig(req.Config).Validate()); invalid != nil {
+		return nil, invalid
+	}
+
+	return &pb.ValidateClientConfigResponse{
+		Valid: true,
+	}, nil
+}
+
+// invalidArgument converts a configuration validation error to an
+// InvalidArgument status with BadRequest details. It returns nil for other errors.
+func invalidArgument(err error) error {
+	var invalid *types.ValidationError
+	if !errors.As(err, &invalid) {
+		return nil
+	}
+
+	// Configuration fields are nested in the request's config message
+	nested := &types.ValidationError{}
+	for _, violation := range invalid.Violations {
+		if violation.Field != "update_mask" {
+			violation.Field = "config." + violation.Field
+		}
+		nested.Violations = append(nested.Violations, violation)
+	}
+	return badRequest(nested)
+}
+
+// invalidMessage converts the validation error of a message nested in a
+// request as field to an InvalidArgument status with BadRequest details, or
+// an Internal status for other errors
+func invalidMessage(field string, err error) error {
+	var invalid *types.ValidationError
+	if !errors.As(err, &invalid) {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	nested := &types.ValidationError{}
+	for _, violation := range invalid.Violations {
+		violation.Field = field + "." + violation.Field
+		nested.Violations = append(nested.Violations, violation)
+	}
+	return badRequest(nested)
+}
+
+// invalidOverride converts the validation error of a limit override to an
+// InvalidArgument status with BadRequest details, nesting the override's
+// fields in the request's override message. It returns nil for other errors.
+func invalidOverride(err error) error {
+	var invalid *types.ValidationError
+	if !errors.As(err, &invalid) {
+		return nil
+	}
+
+	nested := &types.ValidationError{}
+	for _, violation := range invalid.Violations {
+		if violation.Field != "ttl_seconds" {
+			violation.Field = "override." + violation.Field
+		}
+		nested.Violations = append(nested.Violations, violation)
+	}
+	return badRequest(nested)
+}
+
+// badRequest returns an InvalidArgument status with BadRequest details
+// naming the invalid request fields
+func badRequest(invalid *types.ValidationError) error {
+	details := &errdetails.BadRequest{}
+	for _, violation := range invalid.Violations {
+		details.FieldViolations = append(details.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       violation.Field,
+			Description: violation.Description,
+		})
+	}
+	return withDetails(status.New(codes.InvalidArgument, invalid.Error()), details)
+}
+
+// changeErrorMessage describes why a configuration change failed
+func changeErrorMessage(clientID string, err error) string {
+	var conflict *limiter.VersionConflictError
+	switch {
+	case errors.As(err, &conflict):
+		return fmt.Sprintf("Version conflict: client is at version %d, expected %d", conflict.Current, conflict.Expected)
+	case errors.Is(err, limiter.ErrVersionNotFound):
+		return "Configuration version not found"
+	case errors.Is(err, types.ErrClientNotFound):
+		return "Client not found"
+	case errors.Is(err, errAuditFailed):
+		log.Printf("Failed to audit configuration change for %s: %v", clientID, err)
+		return "Configuration change could not be audited"
+	}
+	return err.Error()
+}
+
 // Helper functions to convert between proto and internal types
 
 func protoToClientConfig(proto *pb.ClientConfig) *types.ClientConfig {
 	config := &types.ClientConfig{
 		ClientID: proto.ClientId,
+		Template: proto.Template,
 		Enabled:  proto.Enabled,
+		Mode:     proto.Mode,
 	}
 
 	if proto.Rpm != nil {
@@ -180,13 +794,24 @@ func protoToClientConfig(proto *pb.ClientConfig) *types.ClientConfig {
 		config.TPM = &tpm
 	}
 
+	config.Schedule = protoToSchedule(proto.Schedule)
+
 	return config
 }
 
 func clientConfigToProto(config *types.ClientConfig) *pb.ClientConfig {
 	proto := &pb.ClientConfig{
-		ClientId: config.ClientID,
-		Enabled:  config.Enabled,
+		ClientId:    config.ClientID,
+		Enabled:     config.Enabled,
+		Version:     config.Version,
+		AutoCreated: config.AutoCreated,
+		Template:    config.Template,
+		Rule:        config.Rule,
+		Mode:        config.Mode,
+	}
+
+	if !config.UpdatedAt.IsZero() {
+		proto.UpdatedAt = config.UpdatedAt.Unix()
 	}
 
 	if config.RPM != nil {
@@ -199,21 +824,270 @@ func clientConfigToProto(config *types.ClientConfig) *pb.ClientConfig {
 		proto.Tpm = &tpm
 	}
 
+	proto.Schedule = scheduleToProto(config.Schedule)
+
 	return proto
 }
 
+func protoToSchedule(proto *pb.Schedule) *types.Schedule {
+	if proto == nil {
+		return nil
+	}
+
+	schedule := &types.Schedule{
+		Timezone: proto.Timezone,
+		Windows:  make([]types.ScheduleWindow, 0, len(proto.Windows)),
+	}
+	for _, window := range proto.Windows {
+		schedule.Windows = append(schedule.Windows, types.ScheduleWindow{
+			Name:  window.GetName(),
+			Days:  window.GetDays(),
+			Start: window.GetStart(),
+			End:   window.GetEnd(),
+			RPM:   window.Rpm,
+			TPM:   window.Tpm,
+		})
+	}
+	return schedule.Clone()
+}
+
+func scheduleToProto(schedule *types.Schedule) *pb.Schedule {
+	if schedule == nil {
+		return nil
+	}
+
+	schedule = schedule.Clone()
+	proto := &pb.Schedule{Timezone: schedule.Timezone}
+	for _, window := range schedule.Windows {
+		proto.Windows = append(proto.Windows, &pb.ScheduleWindow{
+			Name:  window.Name,
+			Days:  window.Days,
+			Start: window.Start,
+			End:   window.End,
+			Rpm:   window.RPM,
+			Tpm:   window.TPM,
+		})
+	}
+	return proto
+}
+
+func protoToLimitOverride(proto *pb.LimitOverride) *types.LimitOverride {
+	override := &types.LimitOverride{
+		RPMDelta: proto.RpmDelta,
+		TPMDelta: proto.TpmDelta,
+		Reason:   proto.Reason,
+	}
+
+	if proto.Rpm != nil {
+		rpm := *proto.Rpm
+		override.RPM = &rpm
+	}
+
+	if proto.Tpm != nil {
+		tpm := *proto.Tpm
+		override.TPM = &tpm
+	}
+
+	return override
+}
+
+func limitOverrideToProto(override *types.LimitOverride) *pb.LimitOverride {
+	if override == nil {
+		return nil
+	}
+
+	proto := &pb.LimitOverride{
+		RpmDelta:  override.RPMDelta,
+		TpmDelta:  override.TPMDelta,
+		Reason:    override.Reason,
+		Actor:     override.Actor,
+		CreatedAt: override.CreatedAt.Unix(),
+		ExpiresAt: override.ExpiresAt.Unix(),
+	}
+
+	if override.RPM != nil {
+		rpm := *override.RPM
+		proto.Rpm = &rpm
+	}
+
+	if override.TPM != nil {
+		tpm := *override.TPM
+		proto.Tpm = &tpm
+	}
+
+	return proto
+}
+
+func protoToLimitTemplate(proto *pb.LimitTemplate) *types.LimitTemplate {
+	template := &types.LimitTemplate{
+		Name: proto.Name,
+	}
+
+	if proto.Rpm != nil {
+		rpm := *proto.Rpm
+		template.RPM = &rpm
+	}
+
+	if proto.Tpm != nil {
+		tpm := *proto.Tpm
+		template.TPM = &tpm
+	}
+
+	return template
+}
+
+func limitTemplateToProto(template *types.LimitTemplate) *pb.LimitTemplate {
+	proto := &pb.LimitTemplate{
+		Name: template.Name,
+		Rpm:  template.RPM,
+		Tpm:  template.TPM,
+	}
+
+	if !template.UpdatedAt.IsZero() {
+		proto.UpdatedAt = template.UpdatedAt.Unix()
+	}
+
+	return proto
+}
+
+func protoToClientRule(proto *pb.ClientRule) *types.ClientRule {
+	rule := &types.ClientRule{
+		Name:     proto.Name,
+		Pattern:  proto.Pattern,
+		Regex:    proto.Regex,
+		Priority: int(proto.Priority),
+		Shared:   proto.Shared,
+		Template: proto.Template,
+	}
+
+	if proto.Rpm != nil {
+		rpm := *proto.Rpm
+		rule.RPM = &rpm
+	}
+
+	if proto.Tpm != nil {
+		tpm := *proto.Tpm
+		rule.TPM = &tpm
+	}
+
+	return rule
+}
+
+func clientRuleToProto(rule *types.ClientRule) *pb.ClientRule {
+	proto := &pb.ClientRule{
+		Name:     rule.Name,
+		Pattern:  rule.Pattern,
+		Regex:    rule.Regex,
+		Priority: int32(rule.Priority),
+		Shared:   rule.Shared,
+		Template: rule.Template,
+		Rpm:      rule.RPM,
+		Tpm:      rule.TPM,
+	}
+
+	if !rule.UpdatedAt.IsZero() {
+		proto.UpdatedAt = rule.UpdatedAt.Unix()
+	}
+
+	return proto
+}
+
+func effectiveLimitsToProto(limits types.Limits) *pb.EffectiveLimits {
+	return &pb.EffectiveLimits{
+		Rpm:            limits.RPM,
+		Tpm:            limits.TPM,
+		ScheduleWindow: limits.ScheduleWindow,
+	}
+}
+
 func clientStatsToProto(stats *types.ClientStats) *pb.ClientStats {
 	return &pb.ClientStats{
-		ClientId:         stats.ClientID,
-		TotalRequests:    stats.TotalRequests,
-		SuccessRequests:  stats.SuccessRequests,
-		DroppedRequests:  stats.DroppedRequests,
-		RpmDropped:       stats.RPMDropped,
-		TpmDropped:       stats.TPMDropped,
-		TokensUsed:       stats.TokensUsed,
-		RpmRemaining:     stats.RPMRemaining,
-		TpmRemaining:     stats.TPMRemaining,
-		LastRequestTime:  stats.LastRequestTime.Unix(),
-		AvgLatencyMs:     stats.AvgLatencyMs,
-	}
-} 
\ No newline at end of file
+		ClientId:              stats.ClientID,
+		TotalRequests:         stats.TotalRequests,
+		SuccessRequests:       stats.SuccessRequests,
+		DroppedRequests:       stats.DroppedRequests,
+		RpmDropped:            stats.RPMDropped,
+		TpmDropped:            stats.TPMDropped,
+		UpstreamDropped:       stats.UpstreamDropped,
+		TokensUsed:            stats.TokensUsed,
+		RpmRemaining:          stats.RPMRemaining,
+		TpmRemaining:          stats.TPMRemaining,
+		LastRequestTime:       stats.LastRequestTime.Unix(),
+		AvgLatencyMs:          stats.AvgLatencyMs,
+		ProviderRequests:      stats.ProviderRequests,
+		LatencyP50Ms:          stats.LatencyP50Ms,
+		LatencyP90Ms:          stats.LatencyP90Ms,
+		LatencyP99Ms:          stats.LatencyP99Ms,
+		TtfbP50Ms:             stats.TTFBP50Ms,
+		TtfbP90Ms:             stats.TTFBP90Ms,
+		TtfbP99Ms:             stats.TTFBP99Ms,
+		UpstreamP50Ms:         stats.UpstreamP50Ms,
+		UpstreamP90Ms:         stats.UpstreamP90Ms,
+		UpstreamP99Ms:         stats.UpstreamP99Ms,
+		ScheduleWindow:        stats.ScheduleWindow,
+		ShadowDropped:         stats.ShadowDropped,
+		ShadowRpmDropped:      stats.ShadowRPMDropped,
+		ShadowTpmDropped:      stats.ShadowTPMDropped,
+		ShadowUpstreamDropped: stats.ShadowUpstreamDropped,
+	}
+} 
+
+func upstreamStatusToProto(status proxy.UpstreamStatus) *pb.UpstreamStatus {
+	proto := &pb.UpstreamStatus{
+		Name:                status.Name,
+		Strategy:            string(status.Strategy),
+		CircuitState:        status.Circuit.State,
+		ConsecutiveFailures: int32(status.Circuit.ConsecutiveFailures),
+		Retries:             status.Retries,
+	}
+
+	for _, target := range status.Targets {
+		proto.Targets = append(proto.Targets, &pb.UpstreamTarget{
+			Url:         target.URL,
+			Weight:      int32(target.Weight),
+			Healthy:     target.Healthy,
+			Ejected:     target.Ejected,
+			Outstanding: target.Outstanding,
+			Requests:    target.Requests,
+			Failures:    target.Failures,
+		})
+	}
+
+	return proto
+}
+
+func auditEventToProto(event audit.Event) *pb.AuditEvent {
+	proto := &pb.AuditEvent{
+		Id:         event.ID,
+		Timestamp:  event.Time.Unix(),
+		Actor:      event.Actor,
+		Source:     event.Source,
+		RemoteAddr: event.RemoteAddr,
+		Action:     event.Action,
+		ClientId:   event.ClientID,
+		PrevHash:   event.PrevHash,
+		Hash:       event.Hash,
+	}
+
+	if event.Before != nil {
+		proto.Before = clientConfigToProto(event.Before)
+	}
+	if event.After != nil {
+		proto.After = clientConfigToProto(event.After)
+	}
+	proto.Override = limitOverrideToProto(event.Override)
+	if event.TemplateBefore != nil {
+		proto.TemplateBefore = limitTemplateToProto(event.TemplateBefore)
+	}
+	if event.TemplateAfter != nil {
+		proto.TemplateAfter = limitTemplateToProto(event.TemplateAfter)
+	}
+	if event.RuleBefore != nil {
+		proto.RuleBefore = clientRuleToProto(event.RuleBefore)
+	}
+	if event.RuleAfter != nil {
+		proto.RuleAfter = clientRuleToProto(event.RuleAfter)
+	}
+
+	return proto
+}
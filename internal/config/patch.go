@@ -0,0 +1,157 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	pb "flowguard/internal/proto"
+	"flowguard/internal/types"
+
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// MergePatchContentType is the media type of JSON Merge Patch (RFC 7396) documents
+const MergePatchContentType = "application/merge-patch+json"
+
+// applyMergePatch applies a JSON Merge Patch to a copy of a client
+// configuration. A null rpm or tpm removes that limit, a null template
+// removes the template, a null mode restores the default and a null
+// schedule removes the schedule. A schedule object replaces the timezone
+// and the windows it sets, keeping the current ones it leaves out; windows
+// are always replaced as a whole. enabled cannot be null.
+// The server-assigned version, updated_at, auto_created and rule fields are ignored.
+func applyMergePatch(config *types.ClientConfig, patch []byte) (*types.ClientConfig, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &fields); err != nil || fields == nil {
+		return nil, types.NewValidationError("body", "must be a JSON object")
+	}
+
+	patched := config.Clone()
+	for name, value := range fields {
+		isNull := bytes.Equal(bytes.TrimSpace(value), []byte("null"))
+
+		switch name {
+		case "client_id":
+			var clientID string
+			if err := json.Unmarshal(value, &clientID); err != nil || clientID != config.ClientID {
+				return nil, types.NewValidationError("client_id", "cannot be changed")
+			}
+		case "rpm", "tpm":
+			var limit *int64
+			if !isNull {
+				limit = new(int64)
+				if err := json.Unmarshal(value, limit); err != nil {
+					return nil, types.NewValidationError(name, "must be an integer or null")
+				}
+			}
+			if name == "rpm" {
+				patched.RPM = limit
+			} else {
+				patched.TPM = limit
+			}
+		case "template":
+			patched.Template = ""
+			if !isNull && json.Unmarshal(value, &patched.Template) != nil {
+				return nil, types.NewValidationError("template", "must be a string or null")
+			}
+		case "enabled":
+			if isNull || json.Unmarshal(value, &patched.Enabled) != nil {
+				return nil, types.NewValidationError("enabled", "must be a boolean")
+			}
+		case "mode":
+			patched.Mode = ""
+			if !isNull && json.Unmarshal(value, &patched.Mode) != nil {
+				return nil, types.NewValidationError("mode", "must be a string or null")
+			}
+		case "schedule":
+			if isNull {
+				patched.Schedule = nil
+				break
+			}
+			schedule, err := patchSchedule(patched.Schedule, value)
+			if err != nil {
+				return nil, err
+			}
+			patched.Schedule = schedule
+		case "version", "updated_at", "auto_created", "rule":
+		default:
+			return nil, types.NewValidationError(name, "unknown field")
+		}
+	}
+	return patched, nil
+}
+
+// patchSchedule decodes a schedule object from a merge patch, keeping the
+// current timezone and windows if the patch leaves them out
+func patchSchedule(current *types.Schedule, value json.RawMessage) (*types.Schedule, error) {
+	var fields map[string]json.RawMessage
+	schedule := &types.Schedule{}
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if json.Unmarshal(value, &fields) != nil || fields == nil || decoder.Decode(schedule) != nil {
+		return nil, types.NewValidationError("schedule", "must be a schedule object or null")
+	}
+
+	if current != nil {
+		if _, ok := fields["timezone"]; !ok {
+			schedule.Timezone = current.Timezone
+		}
+		if _, ok := fields["windows"]; !ok {
+			schedule.Windows = current.Clone().Windows
+		}
+	}
+	return schedule, nil
+}
+
+// applyFieldMask copies the fields named in a mask from update to a copy of a
+// client configuration. An empty mask copies the fields that are set in
+// update, and "*" copies all of them.
+func applyFieldMask(config *types.ClientConfig, update *pb.ClientConfig, mask *fieldmaskpb.FieldMask) (*types.ClientConfig, error) {
+	paths := mask.GetPaths()
+	if len(paths) == 0 {
+		if update.Rpm != nil {
+			paths = append(paths, "rpm")
+		}
+		if update.Tpm != nil {
+			paths = append(paths, "tpm")
+		}
+		if update.Template != "" {
+			paths = append(paths, "template")
+		}
+		if update.Enabled {
+			paths = append(paths, "enabled")
+		}
+		if update.Mode != "" {
+			paths = append(paths, "mode")
+		}
+		if update.Schedule != nil {
+			paths = append(paths, "schedule")
+		}
+	}
+
+	patched := config.Clone()
+	values := protoToClientConfig(update)
+	for _, path := range paths {
+		switch path {
+		case "*":
+			patched.RPM, patched.TPM, patched.Enabled = values.RPM, values.TPM, values.Enabled
+			patched.Template, patched.Mode, patched.Schedule = values.Template, values.Mode, values.Schedule
+		case "rpm":
+			patched.RPM = values.RPM
+		case "tpm":
+			patched.TPM = values.TPM
+		case "template":
+			patched.Template = values.Template
+		case "enabled":
+			patched.Enabled = values.Enabled
+		case "mode":
+			patched.Mode = values.Mode
+		case "schedule":
+			patched.Schedule = values.Schedule
+		default:
+			return nil, types.NewValidationError("update_mask", fmt.Sprintf("unknown path %q", path))
+		}
+	}
+	return patched, nil
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"errors"
+	"time"
+
+	"flowguard/internal/limiter"
+	pb "flowguard/internal/proto"
+	"flowguard/internal/types"
+)
+
+// validateQuota checks the client and amounts of a quota request
+func validateQuota(clientID string, requests, tokens int64) error {
+	invalid := &types.ValidationError{}
+	if err := (&types.ClientConfig{ClientID: clientID}).Validate(); err != nil && !errors.As(err, &invalid) {
+		return err
+	}
+	if requests < 0 {
+		invalid.Violations = append(invalid.Violations, types.FieldViolation{Field: "requests", Description: "must not be negative"})
+	}
+	if tokens < 0 {
+		invalid.Violations = append(invalid.Violations, types.FieldViolation{Field: "tokens", Description: "must not be negative"})
+	}
+
+	if len(invalid.Violations) > 0 {
+		return invalid
+	}
+	return nil
+}
+
+// quotaReason returns the error type of a denied quota, or empty if it was allowed
+func quotaReason(quota limiter.Quota) string {
+	var rateLimitErr types.RateLimitError
+	if errors.As(quota.Err, &rateLimitErr) {
+		return rateLimitErr.Type
+	}
+	return ""
+}
+
+// shadowReason returns the limit that would have denied a quota of a client
+// in shadow mode, or an empty string
+func shadowReason(quota limiter.Quota) string {
+	var rateLimitErr types.RateLimitError
+	if errors.As(quota.Shadow, &rateLimitErr) {
+		return rateLimitErr.Type
+	}
+	return ""
+}
+
+func quotaToProto(quota limiter.Quota) *pb.QuotaResponse {
+	return &pb.QuotaResponse{
+		Allowed:      quota.Allowed,
+		Reason:       quotaReason(quota),
+		RetryAfterMs: quota.RetryAfter.Milliseconds(),
+		Limits:       limitStatusesToProto(quota.Limits),
+		ShadowReason: shadowReason(quota),
+	}
+}
+
+func limitStatusesToProto(limits []limiter.LimitStatus) []*pb.LimitStatus {
+	var proto []*pb.LimitStatus
+	for _, limit := range limits {
+		proto = append(proto, &pb.LimitStatus{
+			Limit:        limit.Limit,
+			Capacity:     limit.Capacity,
+			Remaining:    limit.Remaining,
+			ResetAfterMs: limit.ResetIn.Milliseconds(),
+		})
+	}
+	return proto
+}
+
+// limitStatusJSON is the REST form of a limit's state
+type limitStatusJSON struct {
+	Limit        string `json:"limit"`
+	Capacity     int64  `json:"capacity"`
+	Remaining    int64  `json:"remaining"`
+	ResetAfterMs int64  `json:"reset_after_ms"`
+}
+
+func limitStatusesToJSON(limits []limiter.LimitStatus) []limitStatusJSON {
+	result := make([]limitStatusJSON, 0, len(limits))
+	for _, limit := range limits {
+		result = append(result, limitStatusJSON{
+			Limit:        limit.Limit,
+			Capacity:     limit.Capacity,
+			Remaining:    limit.Remaining,
+			ResetAfterMs: limit.ResetIn.Milliseconds(),
+		})
+	}
+	return result
+}
+
+// retryAfterSeconds rounds a wait up to whole seconds for the Retry-After header
+func retryAfterSeconds(wait time.Duration) int64 {
+	return int64((wait + time.Second - 1) / time.Second)
+}
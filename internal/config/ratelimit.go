@@ -0,0 +1,126 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"flowguard/internal/limiter"
+	"flowguard/internal/types"
+
+	rlsv3 "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// RateLimitClientKey is the descriptor entry key whose value is the client ID
+// in Envoy rate limit requests
+const RateLimitClientKey = "client_id"
+
+// rateLimitService implements Envoy's rate limit service
+// (envoy.service.ratelimit.v3.RateLimitService) on top of the limiter, so
+// Envoy can enforce FlowGuard limits without proxying through FlowGuard.
+// Each descriptor with a client_id entry counts as one request for that
+// client, costing hits_addend tokens; other descriptors are not limited.
+type rateLimitService struct {
+	rlsv3.UnimplementedRateLimitServiceServer
+	rateLimiter *limiter.Manager
+}
+
+// ShouldRateLimit checks and consumes the limits of each descriptor's client
+func (s *rateLimitService) ShouldRateLimit(ctx context.Context, req *rlsv3.RateLimitRequest) (*rlsv3.RateLimitResponse, error) {
+	response := &rlsv3.RateLimitResponse{
+		OverallCode: rlsv3.RateLimitResponse_OK,
+	}
+
+	for _, descriptor := range req.Descriptors {
+		clientID := ""
+		for _, entry := range descriptor.Entries {
+			if entry.Key == RateLimitClientKey {
+				clientID = entry.Value
+				break
+			}
+		}
+		if clientID == "" {
+			response.Statuses = append(response.Statuses, &rlsv3.RateLimitResponse_DescriptorStatus{
+				Code: rlsv3.RateLimitResponse_OK,
+			})
+			continue
+		}
+
+		// Envoy treats an unset hits_addend as 1
+		hits := int64(req.HitsAddend)
+		if descriptor.HitsAddend != nil {
+			hits = int64(descriptor.HitsAddend.Value)
+		}
+		if hits == 0 {
+			hits = 1
+		}
+
+		status := s.check(clientID, hits)
+		if status.Code == rlsv3.RateLimitResponse_OVER_LIMIT {
+			response.OverallCode = rlsv3.RateLimitResponse_OVER_LIMIT
+		}
+		response.Statuses = append(response.Statuses, status)
+	}
+
+	return response, nil
+}
+
+// check consumes a request and its tokens from a client's limits and reports
+// the limit that was exceeded or, if allowed, the one closest to exhaustion
+func (s *rateLimitService) check(clientID string, hits int64) *rlsv3.RateLimitResponse_DescriptorStatus {
+	err := s.rateLimiter.CheckAndConsume(clientID, hits)
+	clientID = s.rateLimiter.ResolveClientID(clientID)
+
+	status := &rlsv3.RateLimitResponse_DescriptorStatus{
+		Code: rlsv3.RateLimitResponse_OK,
+	}
+	reported := ""
+	switch {
+	case errors.Is(err, types.ErrRPMExceeded):
+		status.Code = rlsv3.RateLimitResponse_OVER_LIMIT
+		reported = "rpm"
+	case errors.Is(err, types.ErrTPMExceeded):
+		status.Code = rlsv3.RateLimitResponse_OVER_LIMIT
+		reported = "tpm"
+	case err != nil:
+		// Upstream saturation has no per-client limit to report
+		status.Code = rlsv3.RateLimitResponse_OVER_LIMIT
+		return status
+	}
+
+	var limit *limiter.LimitStatus
+	for _, candidate := range s.rateLimiter.GetLimitStatus(clientID) {
+		switch {
+		case reported != "":
+			if candidate.Limit == reported {
+				limit = &candidate
+			}
+		case limit == nil || float64(candidate.Remaining)/float64(candidate.Capacity) < float64(limit.Remaining)/float64(limit.Capacity):
+			limit = &candidate
+		}
+	}
+	if limit == nil {
+		return status
+	}
+
+	status.CurrentLimit = &rlsv3.RateLimitResponse_RateLimit{
+		Name:            clientID + "/" + limit.Limit,
+		RequestsPerUnit: clampUint32(limit.Capacity),
+		Unit:            rlsv3.RateLimitResponse_RateLimit_MINUTE,
+	}
+	status.LimitRemaining = clampUint32(limit.Remaining)
+	status.DurationUntilReset = durationpb.New(limit.ResetIn)
+	return status
+}
+
+// clampUint32 converts a count to the uint32 used by the Envoy API
+func clampUint32(value int64) uint32 {
+	if value < 0 {
+		return 0
+	}
+	if value > math.MaxUint32 {
+		return math.MaxUint32
+	}
+	return uint32(value)
+}
@@ -2,9 +2,20 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
+	"flowguard/internal/audit"
 	"flowguard/internal/limiter"
+	"flowguard/internal/proxy"
 	"flowguard/internal/types"
 
 	"github.com/gorilla/mux"
@@ -13,6 +24,8 @@ import (
 // RESTServer provides REST API endpoints for FlowGuard configuration
 type RESTServer struct {
 	rateLimiter *limiter.Manager
+	upstreams   []*proxy.Pool
+	auditLog    *audit.Log
 	router      *mux.Router
 }
 
@@ -20,7 +33,8 @@ type RESTServer struct {
 func NewRESTServer(rateLimiter *limiter.Manager) *RESTServer {
 	server := &RESTServer{
 		rateLimiter: rateLimiter,
-		router:      mux.NewRouter(),
+		// Client IDs may contain slashes, sent escaped as %2F
+		router: mux.NewRouter().UseEncodedPath(),
 	}
 
 	server.setupRoutes()
@@ -34,14 +48,47 @@ func (s *RESTServer) setupRoutes() {
 	// Client configuration endpoints
 	api.HandleFunc("/clients", s.listClients).Methods("GET")
 	api.HandleFunc("/clients", s.createClient).Methods("POST")
+	api.HandleFunc("/clients/validate", s.validateClient).Methods("POST")
 	api.HandleFunc("/clients/{client_id}", s.getClient).Methods("GET")
 	api.HandleFunc("/clients/{client_id}", s.updateClient).Methods("PUT")
+	api.HandleFunc("/clients/{client_id}", s.patchClient).Methods("PATCH")
 	api.HandleFunc("/clients/{client_id}", s.deleteClient).Methods("DELETE")
+	api.HandleFunc("/clients/{client_id}/history", s.getClientHistory).Methods("GET")
+	api.HandleFunc("/clients/{client_id}/rollback", s.rollbackClient).Methods("POST")
+	api.HandleFunc("/clients/{client_id}/override", s.getOverride).Methods("GET")
+	api.HandleFunc("/clients/{client_id}/override", s.putOverride).Methods("PUT")
+	api.HandleFunc("/clients/{client_id}/override", s.deleteOverride).Methods("DELETE")
+
+	// Limit template endpoints
+	api.HandleFunc("/templates", s.listTemplates).Methods("GET")
+	api.HandleFunc("/templates/{name}", s.getTemplate).Methods("GET")
+	api.HandleFunc("/templates/{name}", s.putTemplate).Methods("PUT")
+	api.HandleFunc("/templates/{name}", s.deleteTemplate).Methods("DELETE")
+
+	// Client rule endpoints
+	api.HandleFunc("/rules", s.listRules).Methods("GET")
+	api.HandleFunc("/rules/{name}", s.getRule).Methods("GET")
+	api.HandleFunc("/rules/{name}", s.putRule).Methods("PUT")
+	api.HandleFunc("/rules/{name}", s.deleteRule).Methods("DELETE")
 
 	// Client statistics endpoints
 	api.HandleFunc("/clients/{client_id}/stats", s.getClientStats).Methods("GET")
 	api.HandleFunc("/stats", s.getAllStats).Methods("GET")
 
+	// Quota endpoints for callers outside the proxy
+	api.HandleFunc("/quota/check", s.checkQuota).Methods("POST")
+	api.HandleFunc("/quota/consume", s.consumeQuota).Methods("POST")
+	api.HandleFunc("/quota/refund", s.refundQuota).Methods("POST")
+
+	// Upstream status endpoints
+	api.HandleFunc("/upstreams", s.listUpstreams).Methods("GET")
+
+	// Configuration audit log
+	api.HandleFunc("/audit", s.listAuditEvents).Methods("GET")
+
+	// Server-sent stream of configuration changes and stats updates
+	api.HandleFunc("/events", s.streamEvents).Methods("GET")
+
 	// Health check
 	s.router.HandleFunc("/health", s.healthCheck).Methods("GET")
 
@@ -49,81 +96,259 @@ func (s *RESTServer) setupRoutes() {
 	s.router.Use(corsMiddleware)
 }
 
+// RegisterUpstream exposes an upstream pool through the admin API
+func (s *RESTServer) RegisterUpstream(pool *proxy.Pool) {
+	s.upstreams = append(s.upstreams, pool)
+}
+
+// SetAuditLog records configuration changes made through the API in an audit log
+func (s *RESTServer) SetAuditLog(auditLog *audit.Log) {
+	s.auditLog = auditLog
+}
+
 // ServeHTTP implements http.Handler
 func (s *RESTServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	s.router.ServeHTTP(w, r)
 }
 
-// listClients returns all client configurations
+// listClients returns a page of client configurations with their statistics.
+// Clients can be filtered by enabled, has_limits, id_prefix and a
+// seen_after/seen_before range (RFC 3339), ordered by sort_by and order
+// (asc or desc), and paged with page_size and page_token.
 func (s *RESTServer) listClients(w http.ResponseWriter, r *http.Request) {
-	clients := s.rateLimiter.GetAllClients()
-	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+	query := r.URL.Query()
+	clientQuery := limiter.ClientQuery{
+		IDPrefix:  query.Get("id_prefix"),
+		SortBy:    query.Get("sort_by"),
+		PageToken: query.Get("page_token"),
+	}
+
+	for name, target := range map[string]**bool{"enabled": &clientQuery.Enabled, "has_limits": &clientQuery.HasLimits} {
+		if value := query.Get(name); value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				s.writeError(w, http.StatusBadRequest, "invalid_parameter", name+" must be true or false")
+				return
+			}
+			*target = &parsed
+		}
+	}
+
+	for name, target := range map[string]*time.Time{"seen_after": &clientQuery.SeenAfter, "seen_before": &clientQuery.SeenBefore} {
+		if value := query.Get(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				s.writeError(w, http.StatusBadRequest, "invalid_parameter", name+" must be an RFC 3339 timestamp")
+				return
+			}
+			*target = parsed
+		}
+	}
+
+	switch query.Get("order") {
+	case "", "asc":
+	case "desc":
+		clientQuery.Descending = true
+	default:
+		s.writeError(w, http.StatusBadRequest, "invalid_parameter", "order must be asc or desc")
+		return
+	}
+
+	if value := query.Get("page_size"); value != "" {
+		pageSize, err := strconv.Atoi(value)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, "invalid_parameter", "page_size must be an integer")
+			return
+		}
+		clientQuery.PageSize = pageSize
+	}
+
+	page, err := s.rateLimiter.ListClients(clientQuery)
+	var invalid *types.ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		s.writeProblem(w, r, "Client listing request is invalid", invalid)
+		return
+	case err != nil:
+		s.writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+		return
+	}
+
+	clients := make([]*types.ClientConfig, 0, len(page.Clients))
+	stats := make([]*types.ClientStats, 0, len(page.Clients))
+	for _, client := range page.Clients {
+		clients = append(clients, client.Config)
+		stats = append(stats, client.Stats)
+	}
+
+	response := map[string]interface{}{
 		"clients": clients,
+		"stats":   stats,
 		"count":   len(clients),
-	})
+		"total":   page.Total,
+	}
+	if page.NextPageToken != "" {
+		response["next_page_token"] = page.NextPageToken
+	}
+	s.writeJSON(w, http.StatusOK, response)
 }
 
 // createClient creates a new client configuration
 func (s *RESTServer) createClient(w http.ResponseWriter, r *http.Request) {
 	var config types.ClientConfig
-	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
-		s.writeError(w, http.StatusBadRequest, "invalid_json", "Invalid JSON body")
+	if !s.decodeConfig(w, r, &config) {
+		return
+	}
+
+	expectedVersion, ok := s.parseIfMatch(w, r)
+	if !ok {
 		return
 	}
 
-	if config.ClientID == "" {
-		s.writeError(w, http.StatusBadRequest, "missing_field", "client_id is required")
+	stored, err := s.rateLimiter.UpdateClient(config.ClientID, expectedVersion, func(*types.ClientConfig) (*types.ClientConfig, error) {
+		return &config, nil
+	}, s.auditHook(r, ""))
+	if err != nil {
+		s.writeChangeError(w, r, config.ClientID, err)
 		return
 	}
 
-	s.rateLimiter.SetClientConfig(&config)
+	setETag(w, stored)
 	s.writeJSON(w, http.StatusCreated, map[string]interface{}{
 		"success": true,
 		"message": "Client configuration created successfully",
-		"config":  config,
+		"config":  stored,
 	})
 }
 
+// validateClient checks a client configuration without applying it
+func (s *RESTServer) validateClient(w http.ResponseWriter, r *http.Request) {
+	var config types.ClientConfig
+	if !s.decodeConfig(w, r, &config) {
+		return
+	}
+
+	var invalid *types.ValidationError
+	if err := config.Validate(); errors.As(err, &invalid) {
+		s.writeValidationError(w, r, invalid)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"valid":  true,
+		"config": config,
+	})
+}
+
+// clientResponse is a client configuration with the limits enforced for it
+type clientResponse struct {
+	*types.ClientConfig
+	Effective types.Limits         `json:"effective"`          // Limits once the schedule, rule, template and override are applied
+	Override  *types.LimitOverride `json:"override,omitempty"` // Temporary override in place, if any
+}
+
 // getClient returns a specific client configuration
 func (s *RESTServer) getClient(w http.ResponseWriter, r *http.Request) {
-	clientID := mux.Vars(r)["client_id"]
+	clientID := pathVar(r, "client_id")
 	
 	config, exists := s.rateLimiter.GetClientConfig(clientID)
 	if !exists {
 		s.writeError(w, http.StatusNotFound, "client_not_found", "Client not found")
 		return
 	}
+	effective, _ := s.rateLimiter.GetEffectiveLimits(clientID)
+	override, _ := s.rateLimiter.GetOverride(clientID)
 
-	s.writeJSON(w, http.StatusOK, config)
+	setETag(w, config)
+	s.writeJSON(w, http.StatusOK, clientResponse{ClientConfig: config, Effective: effective, Override: override})
 }
 
 // updateClient updates a client configuration
 func (s *RESTServer) updateClient(w http.ResponseWriter, r *http.Request) {
-	clientID := mux.Vars(r)["client_id"]
+	clientID := pathVar(r, "client_id")
 	
 	var config types.ClientConfig
-	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+	if !s.decodeConfig(w, r, &config) {
+		return
+	}
+
+	expectedVersion, ok := s.parseIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	// The client ID is taken from the URL parameter
+	stored, err := s.rateLimiter.UpdateClient(clientID, expectedVersion, func(*types.ClientConfig) (*types.ClientConfig, error) {
+		return &config, nil
+	}, s.auditHook(r, ""))
+	if err != nil {
+		s.writeChangeError(w, r, clientID, err)
+		return
+	}
+
+	setETag(w, stored)
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Client configuration updated successfully",
+		"config":  stored,
+	})
+}
+
+// patchClient applies a JSON Merge Patch to a client configuration, leaving
+// fields that are not in the patch unchanged
+func (s *RESTServer) patchClient(w http.ResponseWriter, r *http.Request) {
+	clientID := pathVar(r, "client_id")
+
+	if contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); contentType != "" &&
+		contentType != MergePatchContentType && contentType != "application/json" {
+		s.writeError(w, http.StatusUnsupportedMediaType, "unsupported_media_type", "Content-Type must be "+MergePatchContentType)
+		return
+	}
+
+	patch, err := io.ReadAll(r.Body)
+	if err != nil || !json.Valid(patch) {
 		s.writeError(w, http.StatusBadRequest, "invalid_json", "Invalid JSON body")
 		return
 	}
 
-	// Ensure the client ID matches the URL parameter
-	config.ClientID = clientID
+	expectedVersion, ok := s.parseIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	stored, err := s.rateLimiter.UpdateClient(clientID, expectedVersion, func(current *types.ClientConfig) (*types.ClientConfig, error) {
+		if current == nil {
+			return nil, types.ErrClientNotFound
+		}
+		return applyMergePatch(current, patch)
+	}, s.auditHook(r, ""))
+	if err != nil {
+		s.writeChangeError(w, r, clientID, err)
+		return
+	}
 
-	s.rateLimiter.SetClientConfig(&config)
+	setETag(w, stored)
 	s.writeJSON(w, http.StatusOK, map[string]interface{}{
 		"success": true,
 		"message": "Client configuration updated successfully",
-		"config":  config,
+		"config":  stored,
 	})
 }
 
 // deleteClient removes a client configuration
 func (s *RESTServer) deleteClient(w http.ResponseWriter, r *http.Request) {
-	clientID := mux.Vars(r)["client_id"]
-	
-	if deleted := s.rateLimiter.DeleteClient(clientID); !deleted {
-		s.writeError(w, http.StatusNotFound, "client_not_found", "Client not found")
+	clientID := pathVar(r, "client_id")
+
+	expectedVersion, ok := s.parseIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	_, err := s.rateLimiter.UpdateClient(clientID, expectedVersion, func(*types.ClientConfig) (*types.ClientConfig, error) {
+		return nil, nil
+	}, s.auditHook(r, ""))
+	if err != nil {
+		s.writeChangeError(w, r, clientID, err)
 		return
 	}
 
@@ -133,9 +358,286 @@ func (s *RESTServer) deleteClient(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// getClientHistory returns the retained versions of a client's configuration
+func (s *RESTServer) getClientHistory(w http.ResponseWriter, r *http.Request) {
+	clientID := pathVar(r, "client_id")
+
+	history, exists := s.rateLimiter.GetClientHistory(clientID)
+	if !exists {
+		s.writeError(w, http.StatusNotFound, "client_not_found", "Client not found")
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"client_id": clientID,
+		"versions":  history,
+		"count":     len(history),
+	})
+}
+
+// rollbackClient restores a previous version of a client's configuration
+func (s *RESTServer) rollbackClient(w http.ResponseWriter, r *http.Request) {
+	clientID := pathVar(r, "client_id")
+
+	var request struct {
+		Version int64 `json:"version"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		s.writeError(w, http.StatusBadRequest, "invalid_json", "Invalid JSON body")
+		return
+	}
+	if request.Version <= 0 {
+		s.writeError(w, http.StatusBadRequest, "missing_field", "version is required")
+		return
+	}
+
+	expectedVersion, ok := s.parseIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	stored, err := s.rateLimiter.RollbackClient(clientID, request.Version, expectedVersion, s.auditHook(r, audit.ActionRollback))
+	if err != nil {
+		s.writeChangeError(w, r, clientID, err)
+		return
+	}
+
+	setETag(w, stored)
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("Client configuration rolled back to version %d", request.Version),
+		"config":  stored,
+	})
+}
+
+// overrideRequest is the body of a limit override request
+type overrideRequest struct {
+	RPM        *int64 `json:"rpm"`
+	TPM        *int64 `json:"tpm"`
+	RPMDelta   int64  `json:"rpm_delta"`
+	TPMDelta   int64  `json:"tpm_delta"`
+	Reason     string `json:"reason"`
+	TTLSeconds int64  `json:"ttl_seconds"`
+}
+
+// getOverride returns a client's limit override
+func (s *RESTServer) getOverride(w http.ResponseWriter, r *http.Request) {
+	override, exists := s.rateLimiter.GetOverride(pathVar(r, "client_id"))
+	if !exists {
+		s.writeError(w, http.StatusNotFound, "override_not_found", "Client has no limit override")
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, override)
+}
+
+// putOverride applies a temporary limit override to a client, replacing any it has
+func (s *RESTServer) putOverride(w http.ResponseWriter, r *http.Request) {
+	clientID := pathVar(r, "client_id")
+
+	var request overrideRequest
+	if !s.decodeBody(w, r, &request, "Limit override is invalid") {
+		return
+	}
+
+	actor, remoteAddr := restActor(r)
+	override := &types.LimitOverride{
+		RPM:      request.RPM,
+		TPM:      request.TPM,
+		RPMDelta: request.RPMDelta,
+		TPMDelta: request.TPMDelta,
+		Reason:   request.Reason,
+		Actor:    actor,
+	}
+	ttl := time.Duration(request.TTLSeconds) * time.Second
+	stored, err := s.rateLimiter.SetOverride(clientID, override, ttl, overrideAuditHook(s.auditLog, actor, audit.SourceREST, remoteAddr))
+	var invalid *types.ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		s.writeProblem(w, r, "Limit override is invalid", invalid)
+		return
+	case err != nil:
+		s.writeChangeError(w, r, clientID, err)
+		return
+	}
+
+	effective, _ := s.rateLimiter.GetEffectiveLimits(clientID)
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success":   true,
+		"message":   "Limit override applied until " + stored.ExpiresAt.Format(time.RFC3339),
+		"override":  stored,
+		"effective": effective,
+	})
+}
+
+// deleteOverride removes a client's limit override before it expires
+func (s *RESTServer) deleteOverride(w http.ResponseWriter, r *http.Request) {
+	clientID := pathVar(r, "client_id")
+
+	actor, remoteAddr := restActor(r)
+	err := s.rateLimiter.ClearOverride(clientID, overrideAuditHook(s.auditLog, actor, audit.SourceREST, remoteAddr))
+	switch {
+	case errors.Is(err, limiter.ErrOverrideNotFound):
+		s.writeError(w, http.StatusNotFound, "override_not_found", "Client has no limit override")
+		return
+	case err != nil:
+		s.writeChangeError(w, r, clientID, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Limit override removed",
+	})
+}
+
+// listTemplates returns all limit templates
+func (s *RESTServer) listTemplates(w http.ResponseWriter, r *http.Request) {
+	templates := s.rateLimiter.ListTemplates()
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"templates": templates,
+		"count":     len(templates),
+	})
+}
+
+// getTemplate returns a specific limit template
+func (s *RESTServer) getTemplate(w http.ResponseWriter, r *http.Request) {
+	template, exists := s.rateLimiter.GetTemplate(pathVar(r, "name"))
+	if !exists {
+		s.writeError(w, http.StatusNotFound, "template_not_found", "Template not found")
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, template)
+}
+
+// putTemplate creates or replaces a limit template. Clients using it are
+// updated immediately and keep their bucket levels.
+func (s *RESTServer) putTemplate(w http.ResponseWriter, r *http.Request) {
+	var template types.LimitTemplate
+	if !s.decodeBody(w, r, &template, "Limit template is invalid") {
+		return
+	}
+
+	// The name is taken from the URL parameter
+	template.Name = pathVar(r, "name")
+	actor, remoteAddr := restActor(r)
+	stored, updated, err := s.rateLimiter.SetTemplate(&template, templateAuditHook(s.auditLog, actor, audit.SourceREST, remoteAddr))
+	var invalid *types.ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		s.writeProblem(w, r, "Limit template is invalid", invalid)
+		return
+	case err != nil:
+		s.writeChangeError(w, r, template.Name, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success":         true,
+		"message":         fmt.Sprintf("Template saved; limits of %d clients updated", updated),
+		"template":        stored,
+		"updated_clients": updated,
+	})
+}
+
+// deleteTemplate removes a limit template that no client uses
+func (s *RESTServer) deleteTemplate(w http.ResponseWriter, r *http.Request) {
+	name := pathVar(r, "name")
+
+	actor, remoteAddr := restActor(r)
+	err := s.rateLimiter.DeleteTemplate(name, templateAuditHook(s.auditLog, actor, audit.SourceREST, remoteAddr))
+	var inUse *limiter.TemplateInUseError
+	switch {
+	case errors.Is(err, limiter.ErrTemplateNotFound):
+		s.writeError(w, http.StatusNotFound, "template_not_found", "Template not found")
+		return
+	case errors.As(err, &inUse):
+		s.writeError(w, http.StatusConflict, "template_in_use", "Template is in use: "+inUse.Error())
+		return
+	case err != nil:
+		s.writeChangeError(w, r, name, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Template deleted successfully",
+	})
+}
+
+// listRules returns all client rules in the order they are evaluated
+func (s *RESTServer) listRules(w http.ResponseWriter, r *http.Request) {
+	rules := s.rateLimiter.ListRules()
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"rules": rules,
+		"count": len(rules),
+	})
+}
+
+// getRule returns a specific client rule
+func (s *RESTServer) getRule(w http.ResponseWriter, r *http.Request) {
+	rule, exists := s.rateLimiter.GetRule(pathVar(r, "name"))
+	if !exists {
+		s.writeError(w, http.StatusNotFound, "rule_not_found", "Client rule not found")
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, rule)
+}
+
+// putRule creates or replaces a client rule
+func (s *RESTServer) putRule(w http.ResponseWriter, r *http.Request) {
+	var rule types.ClientRule
+	if !s.decodeBody(w, r, &rule, "Client rule is invalid") {
+		return
+	}
+
+	// The name is taken from the URL parameter
+	rule.Name = pathVar(r, "name")
+	actor, remoteAddr := restActor(r)
+	stored, err := s.rateLimiter.SetRule(&rule, ruleAuditHook(s.auditLog, actor, audit.SourceREST, remoteAddr))
+	var invalid *types.ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		s.writeProblem(w, r, "Client rule is invalid", invalid)
+		return
+	case err != nil:
+		s.writeChangeError(w, r, rule.Name, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Client rule saved successfully",
+		"rule":    stored,
+	})
+}
+
+// deleteRule removes a client rule and evicts the clients created for it
+func (s *RESTServer) deleteRule(w http.ResponseWriter, r *http.Request) {
+	name := pathVar(r, "name")
+
+	actor, remoteAddr := restActor(r)
+	err := s.rateLimiter.DeleteRule(name, ruleAuditHook(s.auditLog, actor, audit.SourceREST, remoteAddr))
+	switch {
+	case errors.Is(err, limiter.ErrRuleNotFound):
+		s.writeError(w, http.StatusNotFound, "rule_not_found", "Client rule not found")
+		return
+	case err != nil:
+		s.writeChangeError(w, r, name, err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Client rule deleted successfully",
+	})
+}
+
 // getClientStats returns statistics for a specific client
 func (s *RESTServer) getClientStats(w http.ResponseWriter, r *http.Request) {
-	clientID := mux.Vars(r)["client_id"]
+	clientID := pathVar(r, "client_id")
 	
 	stats, exists := s.rateLimiter.GetClientStats(clientID)
 	if !exists {
@@ -155,6 +657,384 @@ func (s *RESTServer) getAllStats(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// quotaRequest is the body of the quota endpoints
+type quotaRequest struct {
+	ClientID string `json:"client_id"`
+	Requests *int64 `json:"requests,omitempty"` // Defaults to 1
+	Tokens   int64  `json:"tokens"`
+}
+
+// checkQuota reports whether a client's quota allows a call, without consuming it
+func (s *RESTServer) checkQuota(w http.ResponseWriter, r *http.Request) {
+	request, requests, ok := s.decodeQuotaRequest(w, r)
+	if !ok {
+		return
+	}
+
+	s.writeQuota(w, s.rateLimiter.CheckQuota(request.ClientID, requests, request.Tokens))
+}
+
+// consumeQuota consumes part of a client's quota for a call made outside the proxy
+func (s *RESTServer) consumeQuota(w http.ResponseWriter, r *http.Request) {
+	request, requests, ok := s.decodeQuotaRequest(w, r)
+	if !ok {
+		return
+	}
+
+	s.writeQuota(w, s.rateLimiter.ConsumeQuota(request.ClientID, requests, request.Tokens))
+}
+
+// refundQuota returns consumed quota that was not used
+func (s *RESTServer) refundQuota(w http.ResponseWriter, r *http.Request) {
+	request, requests, ok := s.decodeQuotaRequest(w, r)
+	if !ok {
+		return
+	}
+
+	limits, err := s.rateLimiter.RefundQuota(request.ClientID, requests, request.Tokens)
+	if err != nil {
+		s.writeError(w, http.StatusNotFound, "client_not_found", "Client not found")
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"message": "Quota refunded successfully",
+		"limits":  limitStatusesToJSON(limits),
+	})
+}
+
+// decodeQuotaRequest decodes and validates a quota request, returning it with
+// its request count. It writes an error response and returns false if the
+// request is invalid.
+func (s *RESTServer) decodeQuotaRequest(w http.ResponseWriter, r *http.Request) (quotaRequest, int64, bool) {
+	var request quotaRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		s.writeError(w, http.StatusBadRequest, "invalid_json", "Invalid JSON body")
+		return request, 0, false
+	}
+
+	requests := int64(1)
+	if request.Requests != nil {
+		requests = *request.Requests
+	}
+
+	err := validateQuota(request.ClientID, requests, request.Tokens)
+	var invalid *types.ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		s.writeProblem(w, r, "Quota request is invalid", invalid)
+		return request, 0, false
+	case err != nil:
+		s.writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+		return request, 0, false
+	}
+	return request, requests, true
+}
+
+// writeQuota writes the result of a quota check, with a Retry-After header if it was denied
+func (s *RESTServer) writeQuota(w http.ResponseWriter, quota limiter.Quota) {
+	if quota.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.FormatInt(retryAfterSeconds(quota.RetryAfter), 10))
+	}
+
+	response := map[string]interface{}{
+		"allowed": quota.Allowed,
+		"limits":  limitStatusesToJSON(quota.Limits),
+	}
+	if !quota.Allowed {
+		response["reason"] = quotaReason(quota)
+		response["retry_after_ms"] = quota.RetryAfter.Milliseconds()
+	}
+	if reason := shadowReason(quota); reason != "" {
+		response["shadow_reason"] = reason
+	}
+	s.writeJSON(w, http.StatusOK, response)
+}
+
+// listUpstreams returns upstream pools with their circuit breaker state and target health
+func (s *RESTServer) listUpstreams(w http.ResponseWriter, r *http.Request) {
+	upstreams := make([]proxy.UpstreamStatus, 0, len(s.upstreams))
+	for _, pool := range s.upstreams {
+		upstreams = append(upstreams, pool.Status())
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"upstreams": upstreams,
+		"count":     len(upstreams),
+	})
+}
+
+// listAuditEvents returns configuration changes, optionally filtered by
+// client_id, a since/until time range (RFC 3339) and a limit
+func (s *RESTServer) listAuditEvents(w http.ResponseWriter, r *http.Request) {
+	if s.auditLog == nil {
+		s.writeError(w, http.StatusNotFound, "audit_disabled", "Audit logging is not enabled")
+		return
+	}
+
+	query := r.URL.Query()
+	filter := audit.Filter{ClientID: query.Get("client_id")}
+
+	for name, target := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if value := query.Get(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				s.writeError(w, http.StatusBadRequest, "invalid_parameter", name+" must be an RFC 3339 timestamp")
+				return
+			}
+			*target = parsed
+		}
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			s.writeError(w, http.StatusBadRequest, "invalid_parameter", "limit must be a non-negative integer")
+			return
+		}
+		filter.Limit = limit
+	}
+
+	events := s.auditLog.Query(filter)
+	if events == nil {
+		events = []audit.Event{}
+	}
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"events": events,
+		"count":  len(events),
+	})
+}
+
+// sseKeepAlive is the time between comments sent on an idle event stream
+// so intermediaries do not close it
+const sseKeepAlive = 15 * time.Second
+
+// configEventJSON is the data of a config event on the event stream
+type configEventJSON struct {
+	limiter.ConfigEvent
+	Snapshot bool `json:"snapshot"` // Part of the configurations sent when the stream starts
+}
+
+// streamEvents streams server-sent events: a config event for each current
+// client configuration, then one for every change. If stats_interval (a Go
+// duration such as 5s) is set, stats events with the change in client
+// statistics follow at that interval. client_id limits both to one client.
+func (s *RESTServer) streamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.writeError(w, http.StatusInternalServerError, "streaming_unsupported", "Streaming is not supported")
+		return
+	}
+
+	query := r.URL.Query()
+	clientID := query.Get("client_id")
+
+	var statsUpdates <-chan []limiter.StatsDelta
+	if value := query.Get("stats_interval"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, "invalid_parameter", "stats_interval must be a duration such as 5s")
+			return
+		}
+		interval, err := statsInterval("stats_interval", parsed)
+		var invalid *types.ValidationError
+		switch {
+		case errors.As(err, &invalid):
+			s.writeProblem(w, r, "Event stream request is invalid", invalid)
+			return
+		case err != nil:
+			s.writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+			return
+		}
+		statsUpdates = s.rateLimiter.WatchStats(r.Context(), clientID, interval)
+	}
+
+	configs, watch := s.rateLimiter.WatchConfigs(clientID)
+	defer watch.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for _, event := range snapshotEvents(configs) {
+		if writeEvent(w, flusher, "config", configEventJSON{ConfigEvent: event, Snapshot: true}) != nil {
+			return
+		}
+	}
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		var err error
+		select {
+		case event, ok := <-watch.Events:
+			if !ok {
+				// The client reconnects and starts over from a new snapshot
+				writeEvent(w, flusher, "error", types.RateLimitError{
+					Type:    "watch_overflow",
+					Message: "Event stream fell too far behind configuration changes",
+				})
+				return
+			}
+			err = writeEvent(w, flusher, "config", configEventJSON{ConfigEvent: event})
+		case deltas, ok := <-statsUpdates:
+			if !ok {
+				return
+			}
+			err = writeEvent(w, flusher, "stats", map[string]interface{}{
+				"time":   time.Now().UTC(),
+				"deltas": deltas,
+			})
+		case <-keepAlive.C:
+			_, err = io.WriteString(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// writeEvent writes a server-sent event with JSON data
+func writeEvent(w http.ResponseWriter, flusher http.Flusher, event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		return err
+	}
+	flusher.Flush()
+	return nil
+}
+
+// auditHook audits changes made by a REST request
+func (s *RESTServer) auditHook(r *http.Request, action string) limiter.ChangeHook {
+	actor, remoteAddr := restActor(r)
+	return auditHook(s.auditLog, action, actor, audit.SourceREST, remoteAddr)
+}
+
+// parseIfMatch returns the version required by the If-Match header, or 0 if
+// there is none. It writes an error response and returns false if the header
+// is not a version ETag.
+func (s *RESTServer) parseIfMatch(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	if value == "" {
+		return 0, true
+	}
+
+	value = strings.Trim(strings.TrimPrefix(value, "W/"), `"`)
+	version, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || version <= 0 {
+		s.writeError(w, http.StatusBadRequest, "invalid_header", "If-Match must be a configuration version ETag")
+		return 0, false
+	}
+	return version, true
+}
+
+// writeChangeError writes the error response for a failed configuration change
+func (s *RESTServer) writeChangeError(w http.ResponseWriter, r *http.Request, clientID string, err error) {
+	var conflict *limiter.VersionConflictError
+	var invalid *types.ValidationError
+	switch {
+	case errors.As(err, &conflict):
+		s.writeError(w, http.StatusPreconditionFailed, "version_conflict",
+			fmt.Sprintf("Client is at version %d, expected %d", conflict.Current, conflict.Expected))
+	case errors.As(err, &invalid):
+		s.writeValidationError(w, r, invalid)
+	case errors.Is(err, limiter.ErrVersionNotFound):
+		s.writeError(w, http.StatusNotFound, "version_not_found", "Configuration version not found")
+	case errors.Is(err, types.ErrClientNotFound):
+		s.writeError(w, http.StatusNotFound, "client_not_found", "Client not found")
+	case errors.Is(err, errAuditFailed):
+		log.Printf("Failed to audit configuration change for %s: %v", clientID, err)
+		s.writeError(w, http.StatusInternalServerError, "audit_failed", "Configuration change could not be audited")
+	default:
+		s.writeError(w, http.StatusInternalServerError, "internal_error", err.Error())
+	}
+}
+
+// decodeConfig strictly decodes a client configuration from the request
+// body. It writes an error response and returns false if the body is not
+// valid JSON or has unknown or mistyped fields.
+func (s *RESTServer) decodeConfig(w http.ResponseWriter, r *http.Request, config *types.ClientConfig) bool {
+	return s.decodeBody(w, r, config, "Client configuration is invalid")
+}
+
+// decodeBody strictly decodes the request body into value, as decodeConfig
+// does; detail describes the problem if fields are unknown or mistyped
+func (s *RESTServer) decodeBody(w http.ResponseWriter, r *http.Request, value interface{}, detail string) bool {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(value)
+	if err == nil {
+		return true
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &typeErr):
+		s.writeProblem(w, r, detail, types.NewValidationError(typeErr.Field, "must be of type "+typeErr.Type.String()))
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		s.writeProblem(w, r, detail, types.NewValidationError(field, "unknown field"))
+	default:
+		s.writeError(w, http.StatusBadRequest, "invalid_json", "Invalid JSON body")
+	}
+	return false
+}
+
+// problem is an RFC 7807 problem details response
+type problem struct {
+	Type          string                 `json:"type"`
+	Title         string                 `json:"title"`
+	Status        int                    `json:"status"`
+	Detail        string                 `json:"detail,omitempty"`
+	Instance      string                 `json:"instance,omitempty"`
+	InvalidParams []types.FieldViolation `json:"invalid-params,omitempty"`
+}
+
+// writeValidationError writes a 422 problem+json response listing the invalid configuration fields
+func (s *RESTServer) writeValidationError(w http.ResponseWriter, r *http.Request, err *types.ValidationError) {
+	s.writeProblem(w, r, "Client configuration is invalid", err)
+}
+
+// writeProblem writes a 422 problem+json response listing invalid request fields
+func (s *RESTServer) writeProblem(w http.ResponseWriter, r *http.Request, detail string, err *types.ValidationError) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(problem{
+		Type:          "about:blank",
+		Title:         http.StatusText(http.StatusUnprocessableEntity),
+		Status:        http.StatusUnprocessableEntity,
+		Detail:        detail,
+		Instance:      r.URL.Path,
+		InvalidParams: err.Violations,
+	})
+}
+
+// pathVar returns an unescaped variable of the request path
+func pathVar(r *http.Request, name string) string {
+	value := mux.Vars(r)[name]
+	if unescaped, err := url.PathUnescape(value); err == nil {
+		return unescaped
+	}
+	return value
+}
+
+// setETag sets the ETag header to a configuration's version
+func setETag(w http.ResponseWriter, config *types.ClientConfig) {
+	w.Header().Set("ETag", fmt.Sprintf(`"%d"`, config.Version))
+}
+
 // healthCheck returns the service health status
 func (s *RESTServer) healthCheck(w http.ResponseWriter, r *http.Request) {
 	s.writeJSON(w, http.StatusOK, map[string]interface{}{
@@ -183,8 +1063,9 @@ func (s *RESTServer) writeError(w http.ResponseWriter, statusCode int, errorType
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Actor, If-Match")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"time"
+
+	"flowguard/internal/limiter"
+	pb "flowguard/internal/proto"
+	"flowguard/internal/types"
+)
+
+// defaultStatsInterval is the time between stats updates when a watcher does not choose one
+const defaultStatsInterval = time.Second
+
+// minStatsInterval is the shortest time allowed between stats updates
+const minStatsInterval = 100 * time.Millisecond
+
+// statsInterval validates the interval of a stats watch, named field in
+// the request, applying the default if it is zero
+func statsInterval(field string, interval time.Duration) (time.Duration, error) {
+	switch {
+	case interval == 0:
+		return defaultStatsInterval, nil
+	case interval < minStatsInterval:
+		return 0, types.NewValidationError(field, "must be at least "+minStatsInterval.String())
+	}
+	return interval, nil
+}
+
+// snapshotEvents returns the events describing the configurations a watch starts with
+func snapshotEvents(configs []*types.ClientConfig) []limiter.ConfigEvent {
+	events := make([]limiter.ConfigEvent, 0, len(configs))
+	for _, config := range configs {
+		events = append(events, limiter.ConfigEvent{
+			Type:     limiter.ConfigUpdated,
+			ClientID: config.ClientID,
+			Config:   config,
+			Time:     config.UpdatedAt,
+		})
+	}
+	return events
+}
+
+func configEventToProto(event limiter.ConfigEvent, snapshot bool) *pb.ClientConfigEvent {
+	proto := &pb.ClientConfigEvent{
+		Type:     event.Type,
+		ClientId: event.ClientID,
+		Snapshot: snapshot,
+	}
+	if event.Config != nil {
+		proto.Config = clientConfigToProto(event.Config)
+	}
+	if !event.Time.IsZero() {
+		proto.Timestamp = event.Time.Unix()
+	}
+	return proto
+}
+
+func statsUpdateToProto(deltas []limiter.StatsDelta) *pb.ClientStatsUpdate {
+	update := &pb.ClientStatsUpdate{
+		Timestamp: time.Now().Unix(),
+	}
+	for _, delta := range deltas {
+		update.Deltas = append(update.Deltas, &pb.ClientStatsDelta{
+			ClientId:        delta.ClientID,
+			TotalRequests:   delta.TotalRequests,
+			SuccessRequests: delta.SuccessRequests,
+			DroppedRequests: delta.DroppedRequests,
+			RpmDropped:      delta.RPMDropped,
+			TpmDropped:      delta.TPMDropped,
+			UpstreamDropped: delta.UpstreamDropped,
+			TokensUsed:      delta.TokensUsed,
+			Stats:           clientStatsToProto(delta.Stats),
+			ShadowDropped:   delta.ShadowDropped,
+		})
+	}
+	return update
+}
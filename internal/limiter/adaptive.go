@@ -0,0 +1,227 @@
+package limiter
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"flowguard/internal/types"
+)
+
+// AdaptiveConfig controls the global admission limiter that follows the upstream provider's capacity
+type AdaptiveConfig struct {
+	MaxRPM           int64         // Starting and maximum global admission rate
+	MinRPM           int64         // Floor for the admission rate
+	IncreaseStep     int64         // Additive increase applied after each healthy interval
+	DecreaseFactor   float64       // Multiplicative decrease applied on throttling signals
+	LowWatermark     float64       // Fraction of the provider limit below which traffic is throttled
+	IncreaseInterval time.Duration // Minimum time between additive increases
+	DecreaseCooldown time.Duration // Minimum time between multiplicative decreases
+}
+
+// UpstreamSignal describes rate limit information reported by the upstream provider.
+// Counts are -1 when the provider did not report them.
+type UpstreamSignal struct {
+	StatusCode        int
+	LimitRequests     int64
+	RemainingRequests int64
+	ResetRequests     time.Duration
+	LimitTokens       int64
+	RemainingTokens   int64
+	ResetTokens       time.Duration
+	RetryAfter        time.Duration
+}
+
+// AdaptiveStats is a point-in-time snapshot of the adaptive limiter
+type AdaptiveStats struct {
+	AdmissionRPM      int64 `json:"admission_rpm"`
+	RemainingRequests int64 `json:"remaining_requests"`
+	RemainingTokens   int64 `json:"remaining_tokens"`
+	Throttled         int64 `json:"throttled"`
+	Decreases         int64 `json:"decreases"`
+}
+
+// AdaptiveLimiter throttles global admission using an AIMD controller driven by
+// upstream 429s and rate limit headers, and rejects requests the provider has
+// told us it will refuse until its limits reset
+type AdaptiveLimiter struct {
+	config AdaptiveConfig
+	bucket *types.TokenBucket
+	mutex  sync.Mutex
+
+	rate         int64
+	lastIncrease time.Time
+	lastDecrease time.Time
+	blockedUntil time.Time
+
+	// Model of the provider's remaining capacity (-1 when unknown)
+	remainingRequests int64
+	remainingTokens   int64
+	requestsResetAt   time.Time
+	tokensResetAt     time.Time
+
+	throttled int64
+	decreases int64
+}
+
+// NewAdaptiveLimiter creates a new adaptive limiter
+func NewAdaptiveLimiter(config AdaptiveConfig) *AdaptiveLimiter {
+	if config.MaxRPM <= 0 {
+		config.MaxRPM = 600
+	}
+	if config.MinRPM <= 0 || config.MinRPM > config.MaxRPM {
+		config.MinRPM = 1
+	}
+	if config.IncreaseStep <= 0 {
+		config.IncreaseStep = config.MaxRPM / 20
+		if config.IncreaseStep == 0 {
+			config.IncreaseStep = 1
+		}
+	}
+	if config.DecreaseFactor <= 0 || config.DecreaseFactor >= 1 {
+		config.DecreaseFactor = 0.5
+	}
+	if config.IncreaseInterval <= 0 {
+		config.IncreaseInterval = 5 * time.Second
+	}
+	if config.DecreaseCooldown <= 0 {
+		config.DecreaseCooldown = time.Second
+	}
+
+	now := time.Now()
+	return &AdaptiveLimiter{
+		config:            config,
+		bucket:            types.NewTokenBucket(config.MaxRPM, config.MaxRPM),
+		rate:              config.MaxRPM,
+		lastIncrease:      now,
+		remainingRequests: -1,
+		remainingTokens:   -1,
+	}
+}
+
+// Admit reports whether a request with the given token estimate may be sent upstream
+func (a *AdaptiveLimiter) Admit(tokens int64) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	now := time.Now()
+
+	admitted := true
+	switch {
+	case now.Before(a.blockedUntil):
+		admitted = false
+	case a.remainingRequests >= 0 && now.Before(a.requestsResetAt) && a.remainingRequests <= 0:
+		admitted = false
+	case a.remainingTokens >= 0 && now.Before(a.tokensResetAt) && a.remainingTokens < tokens:
+		admitted = false
+	case !a.bucket.TryConsume(1):
+		admitted = false
+	}
+
+	if !admitted {
+		a.throttled++
+		return false
+	}
+
+	// Spend the modelled capacity until the provider reports fresh numbers
+	if a.remainingRequests > 0 {
+		a.remainingRequests--
+	}
+	if a.remainingTokens > 0 {
+		a.remainingTokens = max(a.remainingTokens-tokens, 0)
+	}
+
+	return true
+}
+
+// Observe updates the capacity model and admission rate from an upstream response
+func (a *AdaptiveLimiter) Observe(signal UpstreamSignal) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	now := time.Now()
+
+	if signal.RemainingRequests >= 0 {
+		a.remainingRequests = signal.RemainingRequests
+		a.requestsResetAt = now.Add(signal.ResetRequests)
+	}
+	if signal.RemainingTokens >= 0 {
+		a.remainingTokens = signal.RemainingTokens
+		a.tokensResetAt = now.Add(signal.ResetTokens)
+	}
+
+	if signal.StatusCode == 429 {
+		if signal.RetryAfter > 0 {
+			a.blockedUntil = now.Add(signal.RetryAfter)
+		}
+		a.decrease(now)
+		return
+	}
+
+	if belowWatermark(signal.RemainingRequests, signal.LimitRequests, a.config.LowWatermark) ||
+		belowWatermark(signal.RemainingTokens, signal.LimitTokens, a.config.LowWatermark) {
+		a.decrease(now)
+		return
+	}
+
+	if signal.StatusCode < 500 && now.Sub(a.lastIncrease) >= a.config.IncreaseInterval {
+		a.increase(now)
+	}
+}
+
+// Stats returns a snapshot of the adaptive limiter
+func (a *AdaptiveLimiter) Stats() AdaptiveStats {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	return AdaptiveStats{
+		AdmissionRPM:      a.rate,
+		RemainingRequests: a.remainingRequests,
+		RemainingTokens:   a.remainingTokens,
+		Throttled:         a.throttled,
+		Decreases:         a.decreases,
+	}
+}
+
+// decrease multiplicatively lowers the admission rate. Must be called with the mutex held.
+func (a *AdaptiveLimiter) decrease(now time.Time) {
+	if now.Sub(a.lastDecrease) < a.config.DecreaseCooldown {
+		return
+	}
+
+	rate := int64(float64(a.rate) * a.config.DecreaseFactor)
+	if rate < a.config.MinRPM {
+		rate = a.config.MinRPM
+	}
+
+	a.lastDecrease = now
+	a.lastIncrease = now
+	a.decreases++
+	if rate != a.rate {
+		log.Printf("Adaptive limiter: lowering admission rate from %d to %d RPM", a.rate, rate)
+		a.setRate(rate)
+	}
+}
+
+// increase additively raises the admission rate. Must be called with the mutex held.
+func (a *AdaptiveLimiter) increase(now time.Time) {
+	a.lastIncrease = now
+	if a.rate >= a.config.MaxRPM {
+		return
+	}
+	a.setRate(min(a.rate+a.config.IncreaseStep, a.config.MaxRPM))
+}
+
+// setRate applies a new admission rate. Must be called with the mutex held.
+func (a *AdaptiveLimiter) setRate(rate int64) {
+	a.rate = rate
+	a.bucket.SetLimits(rate, rate)
+}
+
+// belowWatermark reports whether a reported remaining count is under the watermark fraction of its limit
+func belowWatermark(remaining, limit int64, watermark float64) bool {
+	if remaining < 0 || limit <= 0 || watermark <= 0 {
+		return false
+	}
+	return float64(remaining) < float64(limit)*watermark
+}
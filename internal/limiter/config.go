@@ -0,0 +1,165 @@
+package limiter
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"flowguard/internal/types"
+)
+
+// maxHistory is the number of configuration versions kept per client
+const maxHistory = 100
+
+// ErrVersionNotFound is returned when rolling back to a version that is not in the history
+var ErrVersionNotFound = errors.New("configuration version not found")
+
+// VersionConflictError is returned when a client's configuration is not at the expected version
+type VersionConflictError struct {
+	ClientID string
+	Expected int64
+	Current  int64 // 0 if the client does not exist
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("client %s is at version %d, expected %d", e.ClientID, e.Current, e.Expected)
+}
+
+// ClientUpdate computes a client's new configuration from its current one,
+// which is nil if the client does not exist. Returning nil deletes the client.
+type ClientUpdate func(current *types.ClientConfig) (*types.ClientConfig, error)
+
+// ChangeHook is called with the manager locked just before a change is
+// applied; after is nil for deletions. Returning an error abandons the change.
+type ChangeHook func(before, after *types.ClientConfig) error
+
+// UpdateClient atomically applies an update to a client's configuration and
+// returns the stored result. If expectedVersion is positive, the update fails
+// with a VersionConflictError unless the client is at that version. The new
+// configuration gets the next version number and is added to the history,
+// and the change is sent to configuration watches.
+func (m *Manager) UpdateClient(clientID string, expectedVersion int64, update ClientUpdate, hook ChangeHook) (*types.ClientConfig, error) {
+	return m.updateClient(clientID, expectedVersion, update, hook, clientOrigin{})
+}
+
+// clientOrigin describes a client created by the manager rather than through the API
+type clientOrigin struct {
+	auto bool   // Created on first use, so it can be evicted
+	rule string // Created for a client rule, which supplies its limits
+}
+
+// updateClient implements UpdateClient. origin is recorded in the new
+// configuration; changes through the API have none, making the client permanent.
+func (m *Manager) updateClient(clientID string, expectedVersion int64, update ClientUpdate, hook ChangeHook, origin clientOrigin) (*types.ClientConfig, error) {
+	m.mutex.Lock()
+	next, removed, err := m.applyUpdate(clientID, expectedVersion, update, hook, origin)
+	observer := m.observer
+	m.mutex.Unlock()
+
+	if removed && observer != nil {
+		observer.ClientRemoved(clientID)
+	}
+	return next, err
+}
+
+// applyUpdate applies a change for updateClient and reports whether it
+// removed the client. Must be called with the mutex held.
+func (m *Manager) applyUpdate(clientID string, expectedVersion int64, update ClientUpdate, hook ChangeHook, origin clientOrigin) (*types.ClientConfig, bool, error) {
+	var current *types.ClientConfig
+	if client, exists := m.clients[clientID]; exists {
+		current = client.config
+	}
+
+	if expectedVersion > 0 {
+		currentVersion := int64(0)
+		if current != nil {
+			currentVersion = current.Version
+		}
+		if currentVersion != expectedVersion {
+			return nil, false, &VersionConflictError{ClientID: clientID, Expected: expectedVersion, Current: currentVersion}
+		}
+	}
+
+	next, err := update(current)
+	if err != nil {
+		return nil, false, err
+	}
+	if next == nil && current == nil {
+		return nil, false, types.ErrClientNotFound
+	}
+
+	if next != nil {
+		next = next.Clone()
+		next.ClientID = clientID
+		if err := next.Validate(); err != nil {
+			return nil, false, err
+		}
+		if _, exists := m.templates[next.Template]; next.Template != "" && !exists {
+			return nil, false, types.NewValidationError("template", "must name an existing limit template")
+		}
+		if rule, exists := m.rules[clientID]; exists && rule.Shared && origin.rule != clientID {
+			// Only the rule itself may create its group client
+			return nil, false, types.NewValidationError("client_id", "is reserved for the group client of a shared client rule")
+		}
+		next.Version = m.lastVersion(clientID) + 1
+		next.UpdatedAt = time.Now().UTC()
+		next.AutoCreated = origin.auto
+		next.Rule = origin.rule
+	}
+
+	if hook != nil {
+		if err := hook(current, next); err != nil {
+			return nil, false, err
+		}
+	}
+
+	if next == nil {
+		m.removeClient(clientID)
+		m.publishConfig(ConfigEvent{Type: ConfigDeleted, ClientID: clientID, Time: time.Now().UTC()})
+		return nil, true, nil
+	}
+
+	m.installClient(next)
+	m.trackAutoClient(clientID, origin.auto)
+	history := append(m.history[clientID], next)
+	if len(history) > maxHistory {
+		history = history[len(history)-maxHistory:]
+	}
+	m.history[clientID] = history
+	m.publishConfig(ConfigEvent{Type: ConfigUpdated, ClientID: clientID, Config: next, Time: next.UpdatedAt})
+	return next, false, nil
+}
+
+// RollbackClient restores a previous version of a client's configuration as a new version
+func (m *Manager) RollbackClient(clientID string, version, expectedVersion int64, hook ChangeHook) (*types.ClientConfig, error) {
+	return m.UpdateClient(clientID, expectedVersion, func(*types.ClientConfig) (*types.ClientConfig, error) {
+		for _, past := range m.history[clientID] {
+			if past.Version == version {
+				return past, nil
+			}
+		}
+		return nil, ErrVersionNotFound
+	}, hook)
+}
+
+// GetClientHistory returns the retained versions of a client's configuration, oldest first.
+// History is kept after a client is deleted so it can be restored.
+func (m *Manager) GetClientHistory(clientID string) ([]*types.ClientConfig, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	history, exists := m.history[clientID]
+	if !exists {
+		return nil, false
+	}
+	return append([]*types.ClientConfig(nil), history...), true
+}
+
+// lastVersion returns the latest version assigned to a client. Must be called with the mutex held.
+func (m *Manager) lastVersion(clientID string) int64 {
+	history := m.history[clientID]
+	if len(history) == 0 {
+		return 0
+	}
+	return history[len(history)-1].Version
+}
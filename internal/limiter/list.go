@@ -0,0 +1,244 @@
+package limiter
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"flowguard/internal/types"
+)
+
+// Client list sort keys
+const (
+	SortClientID   = "client_id"
+	SortTokensUsed = "tokens_used"
+	SortDropRate   = "drop_rate"
+	SortLastSeen   = "last_seen"
+)
+
+// DefaultPageSize is the number of clients listed per page when a query does not choose one
+const DefaultPageSize = 100
+
+// MaxPageSize is the largest number of clients listed per page
+const MaxPageSize = 1000
+
+// ClientQuery selects, orders and pages the clients returned by ListClients
+type ClientQuery struct {
+	Enabled    *bool     // Only clients enabled (true) or disabled (false)
+	HasLimits  *bool     // Only clients with (true) or without (false) an effective RPM or TPM limit
+	IDPrefix   string    // Only clients whose ID starts with this prefix
+	SeenAfter  time.Time // Only clients whose last request was at or after this time
+	SeenBefore time.Time // Only clients whose last request was before this time
+	SortBy     string    // One of the sort keys, SortClientID if empty
+	Descending bool
+	PageSize   int    // DefaultPageSize if zero
+	PageToken  string // NextPageToken of the previous page, empty for the first page
+}
+
+// ClientEntry is a listed client with its statistics
+type ClientEntry struct {
+	Config *types.ClientConfig
+	Stats  *types.ClientStats
+}
+
+// ClientPage is one page of a client listing
+type ClientPage struct {
+	Clients       []ClientEntry
+	NextPageToken string // Empty on the last page
+	Total         int    // Clients matching the query across all pages
+}
+
+// pageCursor identifies the last client of a page. Pages continue after
+// the cursor's position rather than at an offset, so clients added or
+// removed between requests do not shift later pages.
+type pageCursor struct {
+	SortBy     string  `json:"s"`
+	Descending bool    `json:"d,omitempty"`
+	Key        float64 `json:"k,omitempty"`
+	ClientID   string  `json:"id"`
+}
+
+// ListClients returns a page of the clients matching a query, ordered by the
+// sort key and then by client ID. Invalid queries return a *types.ValidationError.
+func (m *Manager) ListClients(query ClientQuery) (ClientPage, error) {
+	if err := query.validate(); err != nil {
+		return ClientPage{}, err
+	}
+	if query.SortBy == "" {
+		query.SortBy = SortClientID
+	}
+	if query.PageSize == 0 {
+		query.PageSize = DefaultPageSize
+	}
+
+	var cursor *pageCursor
+	if query.PageToken != "" {
+		cursor = decodePageToken(query.PageToken)
+		if cursor == nil || cursor.SortBy != query.SortBy || cursor.Descending != query.Descending {
+			return ClientPage{}, types.NewValidationError("page_token", "is invalid or was issued for a different sort order")
+		}
+	}
+
+	// Filter a single snapshot of the clients; latency quantiles are costly,
+	// so they are only computed for the page returned
+	var entries []ClientEntry
+	m.mutex.RLock()
+	for clientID, client := range m.clients {
+		client.mutex.RLock()
+		config, limits := client.config, client.limits
+		client.mutex.RUnlock()
+
+		entry := ClientEntry{Config: config}
+		if stats, exists := m.currentStats(clientID); exists {
+			entry.Stats = stats
+		} else {
+			entry.Stats = &types.ClientStats{ClientID: clientID}
+		}
+		if query.matches(entry, limits) {
+			entries = append(entries, entry)
+		}
+	}
+	m.mutex.RUnlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		return query.before(sortKey(query.SortBy, entries[i]), entries[i].Config.ClientID, sortKey(query.SortBy, entries[j]), entries[j].Config.ClientID)
+	})
+
+	page := ClientPage{Total: len(entries)}
+	start := 0
+	if cursor != nil {
+		start = sort.Search(len(entries), func(i int) bool {
+			return query.before(cursor.Key, cursor.ClientID, sortKey(query.SortBy, entries[i]), entries[i].Config.ClientID)
+		})
+	}
+	end := start + query.PageSize
+	if end >= len(entries) {
+		end = len(entries)
+	} else {
+		last := entries[end-1]
+		page.NextPageToken = encodePageToken(pageCursor{
+			SortBy:     query.SortBy,
+			Descending: query.Descending,
+			Key:        sortKey(query.SortBy, last),
+			ClientID:   last.Config.ClientID,
+		})
+	}
+	page.Clients = entries[start:end]
+
+	m.mutex.RLock()
+	for _, entry := range page.Clients {
+		m.fillLatency(entry.Stats)
+	}
+	m.mutex.RUnlock()
+	return page, nil
+}
+
+// validate checks a query, returning a *types.ValidationError listing every invalid field
+func (q ClientQuery) validate() error {
+	var violations []types.FieldViolation
+
+	switch q.SortBy {
+	case "", SortClientID, SortTokensUsed, SortDropRate, SortLastSeen:
+	default:
+		violations = append(violations, types.FieldViolation{
+			Field:       "sort_by",
+			Description: "must be one of client_id, tokens_used, drop_rate or last_seen",
+		})
+	}
+	if q.PageSize < 0 || q.PageSize > MaxPageSize {
+		violations = append(violations, types.FieldViolation{
+			Field:       "page_size",
+			Description: fmt.Sprintf("must be between 0 and %d", MaxPageSize),
+		})
+	}
+	if !q.SeenAfter.IsZero() && !q.SeenBefore.IsZero() && !q.SeenAfter.Before(q.SeenBefore) {
+		violations = append(violations, types.FieldViolation{
+			Field:       "seen_before",
+			Description: "must be after seen_after",
+		})
+	}
+
+	if len(violations) > 0 {
+		return &types.ValidationError{Violations: violations}
+	}
+	return nil
+}
+
+// matches reports whether a client with the given effective limits passes the query's filters.
+// Limits inherited from a template, rule, schedule or override count, and non-positive ones do not.
+func (q ClientQuery) matches(entry ClientEntry, limits types.Limits) bool {
+	config, stats := entry.Config, entry.Stats
+
+	if q.Enabled != nil && config.Enabled != *q.Enabled {
+		return false
+	}
+	if q.HasLimits != nil && (isLimit(limits.RPM) || isLimit(limits.TPM)) != *q.HasLimits {
+		return false
+	}
+	if !strings.HasPrefix(config.ClientID, q.IDPrefix) {
+		return false
+	}
+	if !q.SeenAfter.IsZero() && stats.LastRequestTime.Before(q.SeenAfter) {
+		return false
+	}
+	if !q.SeenBefore.IsZero() && !stats.LastRequestTime.Before(q.SeenBefore) {
+		return false
+	}
+	return true
+}
+
+// isLimit reports whether an effective limit restricts traffic
+func isLimit(limit *int64) bool {
+	return limit != nil && *limit > 0
+}
+
+// before reports whether the client with key a and ID idA is listed before
+// the one with key b and ID idB. Ties are broken by ascending client ID,
+// unless the list is sorted by client ID in descending order.
+func (q ClientQuery) before(a float64, idA string, b float64, idB string) bool {
+	if a != b {
+		return a < b != q.Descending
+	}
+	if q.SortBy == SortClientID && q.Descending {
+		return idA > idB
+	}
+	return idA < idB
+}
+
+// sortKey returns the numeric sort key of a client; clients sorted by ID all share key 0
+func sortKey(sortBy string, entry ClientEntry) float64 {
+	stats := entry.Stats
+	switch sortBy {
+	case SortTokensUsed:
+		return float64(stats.TokensUsed)
+	case SortDropRate:
+		if stats.TotalRequests == 0 {
+			return 0
+		}
+		return float64(stats.DroppedRequests) / float64(stats.TotalRequests)
+	case SortLastSeen:
+		return float64(stats.LastRequestTime.UnixMicro())
+	}
+	return 0
+}
+
+func encodePageToken(cursor pageCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodePageToken returns the cursor of a page token, or nil if it is malformed
+func decodePageToken(token string) *pageCursor {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil
+	}
+	var cursor pageCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ClientID == "" {
+		return nil
+	}
+	return &cursor
+}
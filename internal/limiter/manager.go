@@ -1,22 +1,61 @@
 package limiter
 
 import (
+	"container/list"
 	"sync"
 	"time"
 
 	"flowguard/internal/types"
 )
 
+// Observer is notified of every rate limiting decision
+type Observer interface {
+	RequestAllowed(clientID string, tokens int64)
+	RequestDropped(clientID string, reason string)
+	RequestShadowDropped(clientID string, reason string) // Forwarded in shadow mode but over the limit
+	ClientEvicted(clientID string, reason string)
+	ClientRemoved(clientID string) // Deleted through the API
+}
+
+// LatencySample holds the timings of a single forwarded request
+type LatencySample struct {
+	Total    time.Duration // From arrival until the response completed
+	TTFB     time.Duration // From arrival until the first response byte was sent
+	Upstream time.Duration // Time spent on the upstream call, excluding local queueing
+}
+
+// clientLatency holds the latency distributions of a client
+type clientLatency struct {
+	total    *types.QuantileSketch
+	ttfb     *types.QuantileSketch
+	upstream *types.QuantileSketch
+}
+
 // Manager handles rate limiting for multiple clients
 type Manager struct {
-	clients map[string]*ClientLimiter
-	stats   map[string]*types.ClientStats
-	mutex   sync.RWMutex
+	clients     map[string]*ClientLimiter
+	stats       map[string]*types.ClientStats
+	latency     map[string]*clientLatency
+	history     map[string][]*types.ClientConfig
+	templates   map[string]*types.LimitTemplate
+	rules       map[string]*clientRule
+	ruleOrder   []*clientRule // Rules in the order they are evaluated
+	overrides   map[string]*activeOverride // Limit overrides by client ID
+	expiryHook  OverrideHook               // Called when an override expires
+	watchers    map[*ConfigWatch]struct{}
+	registry    RegistryConfig
+	autoClients *list.List               // Auto-created clients, most recently used first
+	autoIndex   map[string]*list.Element // Elements of autoClients by client ID
+	adaptive    *AdaptiveLimiter
+	observer    Observer
+	mutex       sync.RWMutex
 }
 
 // ClientLimiter holds the rate limiting state for a single client
 type ClientLimiter struct {
 	config    *types.ClientConfig
+	schedule  *compiledSchedule // nil if the client has no schedule
+	limits    types.Limits      // Limits enforced once the schedule, rule, template and override are applied
 	rpmBucket *types.TokenBucket
 	tpmBucket *types.TokenBucket
 	mutex     sync.RWMutex
@@ -25,95 +64,115 @@ type ClientLimiter struct {
 // NewManager creates a new rate limiter manager
 func NewManager() *Manager {
 	return &Manager{
-		clients: make(map[string]*ClientLimiter),
-		stats:   make(map[string]*types.ClientStats),
+		clients:     make(map[string]*ClientLimiter),
+		stats:       make(map[string]*types.ClientStats),
+		latency:     make(map[string]*clientLatency),
+		history:     make(map[string][]*types.ClientConfig),
+		templates:   make(map[string]*types.LimitTemplate),
+		rules:       make(map[string]*clientRule),
+		overrides:   make(map[string]*activeOverride),
+		watchers:    make(map[*ConfigWatch]struct{}),
+		registry:    RegistryConfig{UnknownClientPolicy: UnknownClientCreate},
+		autoClients: list.New(),
+		autoIndex:   make(map[string]*list.Element),
 	}
 }
 
-// CheckAndConsume checks if a request can proceed and consumes tokens if allowed
+// CheckAndConsume checks if a request can proceed and consumes tokens if
+// allowed. Requests of clients in shadow mode always proceed.
 func (m *Manager) CheckAndConsume(clientID string, tokenEstimate int64) error {
+	return m.ConsumeQuota(clientID, 1, tokenEstimate).Err
+}
+
+// LimitStatus describes the state of one of a client's limits
+type LimitStatus struct {
+	Limit     string        // rpm or tpm
+	Capacity  int64         // Configured limit per minute
+	Remaining int64         // Requests or tokens available now
+	ResetIn   time.Duration // Time until the limit is fully replenished
+}
+
+// GetLimitStatus returns the state of a client's RPM and TPM limits, omitting
+// limits that are not configured or not enforced
+func (m *Manager) GetLimitStatus(clientID string) []LimitStatus {
 	m.mutex.RLock()
 	client, exists := m.clients[clientID]
-	_, statsExists := m.stats[clientID]
 	m.mutex.RUnlock()
-
-	if !exists {
-		// Auto-create client with no limits if not configured
-		m.SetClientConfig(&types.ClientConfig{
-			ClientID: clientID,
-			Enabled:  true,
-		})
-		m.mutex.RLock()
-		client = m.clients[clientID]
-		m.mutex.RUnlock()
-	}
-
-	if !statsExists {
-		m.mutex.Lock()
-		if _, exists := m.stats[clientID]; !exists {
-			m.stats[clientID] = &types.ClientStats{
-				ClientID:        clientID,
-				LastRequestTime: time.Now(),
-			}
-		}
-		m.mutex.Unlock()
-	}
-
-	client.mutex.RLock()
-	config := client.config
-	client.mutex.RUnlock()
-
-	if !config.Enabled {
-		// Rate limiting disabled for this client
-		m.updateSuccessStats(clientID, tokenEstimate)
+	if !exists || !client.config.Enabled {
 		return nil
 	}
 
-	// Check RPM limit
-	if config.RPM != nil && client.rpmBucket != nil {
-		if !client.rpmBucket.TryConsume(1) {
-			m.updateDroppedStats(clientID, "rpm")
-			return types.ErrRPMExceeded
-		}
-	}
+	rpmBucket, tpmBucket := client.buckets()
 
-	// Check TPM limit
-	if config.TPM != nil && client.tpmBucket != nil {
-		if !client.tpmBucket.TryConsume(tokenEstimate) {
-			// Refund the RPM token if TPM check fails
-			if config.RPM != nil && client.rpmBucket != nil {
-				// Note: In a real implementation, you might want to handle this differently
-				// as we can't easily "refund" tokens to a bucket
-			}
-			m.updateDroppedStats(clientID, "tpm")
-			return types.ErrTPMExceeded
+	var statuses []LimitStatus
+	for _, limit := range []struct {
+		name   string
+		bucket *types.TokenBucket
+	}{{"rpm", rpmBucket}, {"tpm", tpmBucket}} {
+		if limit.bucket == nil {
+			continue
 		}
+		capacity, remaining, resetIn := limit.bucket.Status()
+		statuses = append(statuses, LimitStatus{
+			Limit:     limit.name,
+			Capacity:  capacity,
+			Remaining: remaining,
+			ResetIn:   resetIn,
+		})
 	}
+	return statuses
+}
 
-	m.updateSuccessStats(clientID, tokenEstimate)
-	return nil
+// SetObserver registers an observer for rate limiting decisions
+func (m *Manager) SetObserver(observer Observer) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.observer = observer
 }
 
-// SetClientConfig updates or creates a client configuration
-func (m *Manager) SetClientConfig(config *types.ClientConfig) {
+// SetAdaptiveLimiter enables global admission control driven by upstream rate limit signals
+func (m *Manager) SetAdaptiveLimiter(adaptive *AdaptiveLimiter) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
+	m.adaptive = adaptive
+}
 
-	var rpmBucket, tpmBucket *types.TokenBucket
+// AdaptiveLimiter returns the adaptive limiter, or nil if it is disabled
+func (m *Manager) AdaptiveLimiter() *AdaptiveLimiter {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.adaptive
+}
 
-	if config.RPM != nil && *config.RPM > 0 {
-		rpmBucket = types.NewTokenBucket(*config.RPM, *config.RPM)
+// ObserveUpstream feeds upstream rate limit signals to the adaptive limiter
+func (m *Manager) ObserveUpstream(signal UpstreamSignal) {
+	if adaptive := m.AdaptiveLimiter(); adaptive != nil {
+		adaptive.Observe(signal)
 	}
+}
 
-	if config.TPM != nil && *config.TPM > 0 {
-		tpmBucket = types.NewTokenBucket(*config.TPM, *config.TPM)
-	}
+// SetClientConfig updates or creates a client configuration
+func (m *Manager) SetClientConfig(config *types.ClientConfig) error {
+	_, err := m.UpdateClient(config.ClientID, 0, func(*types.ClientConfig) (*types.ClientConfig, error) {
+		return config, nil
+	}, nil)
+	return err
+}
 
-	m.clients[config.ClientID] = &ClientLimiter{
-		config:    config,
-		rpmBucket: rpmBucket,
-		tpmBucket: tpmBucket,
+// installClient creates the limiter for a configuration, or updates the
+// existing one so its buckets keep their levels. Must be called with the mutex held.
+func (m *Manager) installClient(config *types.ClientConfig) {
+	client, exists := m.clients[config.ClientID]
+	if exists {
+		client.mutex.Lock()
+		client.config = config
+		client.schedule = compileSchedule(config.Schedule)
+		client.mutex.Unlock()
+	} else {
+		client = &ClientLimiter{config: config, schedule: compileSchedule(config.Schedule)}
+		m.clients[config.ClientID] = client
 	}
+	client.setLimits(m.effectiveLimits(client, time.Now()))
 
 	// Initialize stats if not exists
 	if _, exists := m.stats[config.ClientID]; !exists {
@@ -140,27 +199,33 @@ func (m *Manager) GetClientConfig(clientID string) (*types.ClientConfig, bool) {
 	return client.config, true
 }
 
+// GetEffectiveLimits returns the limits enforced for a client once its
+// schedule, rule, template and override are applied
+func (m *Manager) GetEffectiveLimits(clientID string) (types.Limits, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	client, exists := m.clients[clientID]
+	if !exists {
+		return types.Limits{}, false
+	}
+
+	client.mutex.RLock()
+	defer client.mutex.RUnlock()
+
+	return client.limits, true
+}
+
 // GetClientStats returns the statistics for a client
 func (m *Manager) GetClientStats(clientID string) (*types.ClientStats, bool) {
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
 
-	stats, exists := m.stats[clientID]
+	stats, exists := m.currentStats(clientID)
 	if !exists {
 		return nil, false
 	}
-
-	// Get current bucket levels
-	if client, clientExists := m.clients[clientID]; clientExists {
-		client.mutex.RLock()
-		if client.rpmBucket != nil {
-			stats.RPMRemaining = client.rpmBucket.GetRemainingTokens()
-		}
-		if client.tpmBucket != nil {
-			stats.TPMRemaining = client.tpmBucket.GetRemainingTokens()
-		}
-		client.mutex.RUnlock()
-	}
+	m.fillLatency(stats)
 
 	return stats, true
 }
@@ -186,56 +251,125 @@ func (m *Manager) GetAllStats() map[string]*types.ClientStats {
 	defer m.mutex.RUnlock()
 
 	result := make(map[string]*types.ClientStats)
-	for clientID, stats := range m.stats {
-		// Update current bucket levels
-		if client, exists := m.clients[clientID]; exists {
-			client.mutex.RLock()
-			if client.rpmBucket != nil {
-				stats.RPMRemaining = client.rpmBucket.GetRemainingTokens()
-			}
-			if client.tpmBucket != nil {
-				stats.TPMRemaining = client.tpmBucket.GetRemainingTokens()
-			}
-			client.mutex.RUnlock()
-		}
+	for clientID := range m.stats {
+		stats, _ := m.currentStats(clientID)
+		m.fillLatency(stats)
 		result[clientID] = stats
 	}
 
 	return result
 }
 
+// currentStats returns a copy of a client's statistics with its current
+// bucket levels, without latency quantiles. Must be called with the mutex held.
+func (m *Manager) currentStats(clientID string) (*types.ClientStats, bool) {
+	stats, exists := m.stats[clientID]
+	if !exists {
+		return nil, false
+	}
+	stats = snapshotStats(stats)
+
+	if client, clientExists := m.clients[clientID]; clientExists {
+		client.mutex.RLock()
+		if client.rpmBucket != nil {
+			stats.RPMRemaining = client.rpmBucket.GetRemainingTokens()
+		}
+		if client.tpmBucket != nil {
+			stats.TPMRemaining = client.tpmBucket.GetRemainingTokens()
+		}
+		stats.ScheduleWindow = client.limits.ScheduleWindow
+		client.mutex.RUnlock()
+	}
+	return stats, true
+}
+
 // DeleteClient removes a client configuration
 func (m *Manager) DeleteClient(clientID string) bool {
-	m.mutex.Lock()
-	defer m.mutex.Unlock()
+	_, err := m.UpdateClient(clientID, 0, func(*types.ClientConfig) (*types.ClientConfig, error) {
+		return nil, nil
+	}, nil)
+	return err == nil
+}
 
-	_, exists := m.clients[clientID]
-	if exists {
-		delete(m.clients, clientID)
-		delete(m.stats, clientID)
+// buckets returns the client's RPM and TPM buckets, nil for limits that are not set
+func (c *ClientLimiter) buckets() (*types.TokenBucket, *types.TokenBucket) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.rpmBucket, c.tpmBucket
+}
+
+// setLimits changes the limits the client enforces. Buckets of limits that
+// remain set keep their current level, so a change does not reset them.
+func (c *ClientLimiter) setLimits(limits types.Limits) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.limits = limits
+	c.rpmBucket = resizeBucket(c.rpmBucket, limits.RPM)
+	c.tpmBucket = resizeBucket(c.tpmBucket, limits.TPM)
+}
+
+// resizeBucket returns a bucket for a per-minute limit, reusing bucket if it
+// exists, or nil if there is no limit
+func resizeBucket(bucket *types.TokenBucket, limit *int64) *types.TokenBucket {
+	switch {
+	case limit == nil || *limit <= 0:
+		return nil
+	case bucket == nil:
+		return types.NewTokenBucket(*limit, *limit)
 	}
+	bucket.SetLimits(*limit, *limit)
+	return bucket
+}
 
-	return exists
+// removeClient deletes a client's limiter, override and statistics, keeping its
+// configuration history. Must be called with the mutex held.
+func (m *Manager) removeClient(clientID string) {
+	m.trackAutoClient(clientID, false)
+	m.dropOverride(clientID)
+	delete(m.clients, clientID)
+	delete(m.stats, clientID)
+	delete(m.latency, clientID)
 }
 
 // updateSuccessStats updates statistics for a successful request
 func (m *Manager) updateSuccessStats(clientID string, tokens int64) {
 	m.mutex.Lock()
-	defer m.mutex.Unlock()
-
-	stats := m.stats[clientID]
+	stats, exists := m.stats[clientID]
+	if !exists {
+		// Evicted or deleted since the request was resolved
+		m.mutex.Unlock()
+		return
+	}
 	stats.TotalRequests++
 	stats.SuccessRequests++
 	stats.TokensUsed += tokens
 	stats.LastRequestTime = time.Now()
+	observer := m.observer
+	m.mutex.Unlock()
+
+	if observer != nil {
+		observer.RequestAllowed(clientID, tokens)
+	}
 }
 
 // updateDroppedStats updates statistics for a dropped request
 func (m *Manager) updateDroppedStats(clientID string, reason string) {
 	m.mutex.Lock()
-	defer m.mutex.Unlock()
+	stats, exists := m.stats[clientID]
+	if !exists {
+		// Evicted or deleted since the request was resolved
+		m.mutex.Unlock()
+		return
+	}
+	observer := m.observer
+	defer func() {
+		m.mutex.Unlock()
+		if observer != nil {
+			observer.RequestDropped(clientID, reason)
+		}
+	}()
 
-	stats := m.stats[clientID]
 	stats.TotalRequests++
 	stats.DroppedRequests++
 	stats.LastRequestTime = time.Now()
@@ -245,11 +379,45 @@ func (m *Manager) updateDroppedStats(clientID string, reason string) {
 		stats.RPMDropped++
 	case "tpm":
 		stats.TPMDropped++
+	case "upstream":
+		stats.UpstreamDropped++
+	}
+}
+
+// updateShadowStats updates statistics for a request forwarded in shadow
+// mode that enforcement would have dropped
+func (m *Manager) updateShadowStats(clientID string, tokens int64, reason string) {
+	m.mutex.Lock()
+	stats, exists := m.stats[clientID]
+	if !exists {
+		// Evicted or deleted since the request was resolved
+		m.mutex.Unlock()
+		return
+	}
+	stats.TotalRequests++
+	stats.SuccessRequests++
+	stats.TokensUsed += tokens
+	stats.LastRequestTime = time.Now()
+	stats.ShadowDropped++
+	switch reason {
+	case "rpm":
+		stats.ShadowRPMDropped++
+	case "tpm":
+		stats.ShadowTPMDropped++
+	case "upstream":
+		stats.ShadowUpstreamDropped++
+	}
+	observer := m.observer
+	m.mutex.Unlock()
+
+	if observer != nil {
+		observer.RequestAllowed(clientID, tokens)
+		observer.RequestShadowDropped(clientID, reason)
 	}
 }
 
-// UpdateLatency updates the average latency for a client
-func (m *Manager) UpdateLatency(clientID string, latencyMs float64) {
+// RecordProvider records which provider served a request for a client
+func (m *Manager) RecordProvider(clientID string, provider string) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
@@ -258,10 +426,69 @@ func (m *Manager) UpdateLatency(clientID string, latencyMs float64) {
 		return
 	}
 
-	// Simple moving average (could be improved with exponential moving average)
-	if stats.AvgLatencyMs == 0 {
-		stats.AvgLatencyMs = latencyMs
-	} else {
-		stats.AvgLatencyMs = (stats.AvgLatencyMs + latencyMs) / 2
+	if stats.ProviderRequests == nil {
+		stats.ProviderRequests = make(map[string]int64)
+	}
+	stats.ProviderRequests[provider]++
+}
+
+// snapshotStats copies client statistics so callers can read them without holding the lock
+func snapshotStats(stats *types.ClientStats) *types.ClientStats {
+	snapshot := *stats
+	if stats.ProviderRequests != nil {
+		snapshot.ProviderRequests = make(map[string]int64, len(stats.ProviderRequests))
+		for provider, count := range stats.ProviderRequests {
+			snapshot.ProviderRequests[provider] = count
+		}
+	}
+	return &snapshot
+}
+
+// RecordLatency records the timings of a forwarded request for a client
+func (m *Manager) RecordLatency(clientID string, sample LatencySample) {
+	m.mutex.Lock()
+	if _, exists := m.stats[clientID]; !exists {
+		m.mutex.Unlock()
+		return
 	}
-} 
\ No newline at end of file
+
+	latency, exists := m.latency[clientID]
+	if !exists {
+		latency = &clientLatency{
+			total:    types.NewQuantileSketch(0.01),
+			ttfb:     types.NewQuantileSketch(0.01),
+			upstream: types.NewQuantileSketch(0.01),
+		}
+		m.latency[clientID] = latency
+	}
+	m.mutex.Unlock()
+
+	latency.total.Add(durationMs(sample.Total))
+	latency.ttfb.Add(durationMs(sample.TTFB))
+	latency.upstream.Add(durationMs(sample.Upstream))
+}
+
+// fillLatency copies the latency distribution of a client into its stats.
+// Must be called with the mutex held.
+func (m *Manager) fillLatency(stats *types.ClientStats) {
+	latency, exists := m.latency[stats.ClientID]
+	if !exists {
+		return
+	}
+
+	stats.AvgLatencyMs = latency.total.Mean()
+	stats.LatencyP50Ms = latency.total.Quantile(0.5)
+	stats.LatencyP90Ms = latency.total.Quantile(0.9)
+	stats.LatencyP99Ms = latency.total.Quantile(0.99)
+	stats.TTFBP50Ms = latency.ttfb.Quantile(0.5)
+	stats.TTFBP90Ms = latency.ttfb.Quantile(0.9)
+	stats.TTFBP99Ms = latency.ttfb.Quantile(0.99)
+	stats.UpstreamP50Ms = latency.upstream.Quantile(0.5)
+	stats.UpstreamP90Ms = latency.upstream.Quantile(0.9)
+	stats.UpstreamP99Ms = latency.upstream.Quantile(0.99)
+}
+
+// durationMs converts a duration to fractional milliseconds
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
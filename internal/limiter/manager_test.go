@@ -0,0 +1,71 @@
+package limiter
+
+import (
+	"testing"
+
+	"flowguard/internal/types"
+)
+
+func TestStatsUpdatesIgnoreRemovedClients(t *testing.T) {
+	m := NewManager()
+	rpm := int64(10)
+	if err := m.SetClientConfig(&types.ClientConfig{ClientID: "gone", RPM: &rpm, Enabled: true}); err != nil {
+		t.Fatalf("SetClientConfig: %v", err)
+	}
+	if !m.DeleteClient("gone") {
+		t.Fatal("DeleteClient returned false")
+	}
+
+	// A request resolved before the client was deleted finishes afterwards
+	m.updateSuccessStats("gone", 5)
+	m.updateDroppedStats("gone", "rpm")
+	m.updateShadowStats("gone", 5, "tpm")
+
+	if _, exists := m.GetClientStats("gone"); exists {
+		t.Error("stats were recreated for a deleted client")
+	}
+}
+
+func TestConfigChangeKeepsBucketLevels(t *testing.T) {
+	m := NewManager()
+	rpm := int64(10)
+	if err := m.SetClientConfig(&types.ClientConfig{ClientID: "busy", RPM: &rpm, Enabled: true}); err != nil {
+		t.Fatalf("SetClientConfig: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := m.CheckAndConsume("busy", 0); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+
+	// Changing an unrelated field must not refill the bucket
+	tpm := int64(1000)
+	if err := m.SetClientConfig(&types.ClientConfig{ClientID: "busy", RPM: &rpm, TPM: &tpm, Enabled: true}); err != nil {
+		t.Fatalf("SetClientConfig: %v", err)
+	}
+	if err := m.CheckAndConsume("busy", 0); err != types.ErrRPMExceeded {
+		t.Errorf("CheckAndConsume after config change = %v, want %v", err, types.ErrRPMExceeded)
+	}
+}
+
+func TestShadowRequestsUseAdmission(t *testing.T) {
+	m := NewManager()
+	m.SetAdaptiveLimiter(NewAdaptiveLimiter(AdaptiveConfig{MaxRPM: 2}))
+	rpm := int64(1)
+	if err := m.SetClientConfig(&types.ClientConfig{ClientID: "shadow", RPM: &rpm, Mode: types.ModeShadow, Enabled: true}); err != nil {
+		t.Fatalf("SetClientConfig: %v", err)
+	}
+
+	// The second request exceeds the client's own limit but is forwarded,
+	// so it takes the last admission slot
+	wantShadow := []error{nil, types.ErrRPMExceeded, types.ErrRPMExceeded}
+	for i, want := range wantShadow {
+		quota := m.ConsumeQuota("shadow", 1, 0)
+		if !quota.Allowed || quota.Shadow != want {
+			t.Errorf("request %d: allowed %t, shadow %v; want allowed, shadow %v", i, quota.Allowed, quota.Shadow, want)
+		}
+	}
+	if throttled := m.AdaptiveLimiter().Stats().Throttled; throttled != 1 {
+		t.Errorf("admission throttled %d requests, want 1", throttled)
+	}
+}
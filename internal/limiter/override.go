@@ -0,0 +1,168 @@
+package limiter
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"flowguard/internal/types"
+)
+
+// MaxOverrideTTL is the longest a limit override can stay in place
+const MaxOverrideTTL = 7 * 24 * time.Hour
+
+// ErrOverrideNotFound is returned when clearing the override of a client that has none
+var ErrOverrideNotFound = errors.New("limit override not found")
+
+// OverrideHook is called with a client's override before and after a change,
+// with the manager locked just before the change is applied, so it must not
+// block; after is nil when the override is removed. Returning an error
+// rejects the change.
+type OverrideHook func(clientID string, before, after *types.LimitOverride) error
+
+// activeOverride is a limit override with the timer that removes it
+type activeOverride struct {
+	override *types.LimitOverride
+	timer    *time.Timer
+}
+
+// SetOverride applies a limit override to a client for ttl, replacing any
+// override it already has. The override stacks on the client's configuration,
+// which can still be changed while it is in place. Invalid overrides return a
+// *types.ValidationError.
+func (m *Manager) SetOverride(clientID string, override *types.LimitOverride, ttl time.Duration, hook OverrideHook) (*types.LimitOverride, error) {
+	invalid := &types.ValidationError{}
+	if err := override.Validate(); err != nil && !errors.As(err, &invalid) {
+		return nil, err
+	}
+	if ttl <= 0 || ttl > MaxOverrideTTL {
+		invalid.Violations = append(invalid.Violations, types.FieldViolation{
+			Field:       "ttl_seconds",
+			Description: fmt.Sprintf("must be between 1 and %d", int64(MaxOverrideTTL/time.Second)),
+		})
+	}
+	if len(invalid.Violations) > 0 {
+		return nil, invalid
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	client, exists := m.clients[clientID]
+	if !exists {
+		return nil, types.ErrClientNotFound
+	}
+
+	stored := override.Clone()
+	stored.CreatedAt = time.Now().UTC()
+	stored.ExpiresAt = stored.CreatedAt.Add(ttl)
+
+	if hook != nil {
+		if err := hook(clientID, m.currentOverride(clientID), stored); err != nil {
+			return nil, err
+		}
+	}
+
+	m.dropOverride(clientID)
+	active := &activeOverride{override: stored}
+	active.timer = time.AfterFunc(ttl, func() { m.expireOverride(clientID, active) })
+	m.overrides[clientID] = active
+	client.setLimits(m.effectiveLimits(client, stored.CreatedAt))
+	return stored, nil
+}
+
+// GetOverride returns a client's limit override, if it has one
+func (m *Manager) GetOverride(clientID string) (*types.LimitOverride, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	override := m.currentOverride(clientID)
+	return override, override != nil
+}
+
+// ClearOverride removes a client's limit override before it expires
+func (m *Manager) ClearOverride(clientID string, hook OverrideHook) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	current := m.currentOverride(clientID)
+	if current == nil {
+		return ErrOverrideNotFound
+	}
+	if hook != nil {
+		if err := hook(clientID, current, nil); err != nil {
+			return err
+		}
+	}
+
+	m.dropOverride(clientID)
+	if client, exists := m.clients[clientID]; exists {
+		client.setLimits(m.effectiveLimits(client, time.Now()))
+	}
+	return nil
+}
+
+// SetOverrideExpiryHook registers a hook called after an override expires.
+// Expiry cannot be rejected, so the hook runs without the manager locked and
+// its errors are only logged.
+func (m *Manager) SetOverrideExpiryHook(hook OverrideHook) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.expiryHook = hook
+}
+
+// expireOverride removes an override when its TTL has passed, unless it was
+// replaced or removed in the meantime
+func (m *Manager) expireOverride(clientID string, active *activeOverride) {
+	m.mutex.Lock()
+	if m.overrides[clientID] != active {
+		m.mutex.Unlock()
+		return
+	}
+
+	delete(m.overrides, clientID)
+	if client, exists := m.clients[clientID]; exists {
+		client.setLimits(m.effectiveLimits(client, time.Now()))
+	}
+	hook := m.expiryHook
+	m.mutex.Unlock()
+
+	log.Printf("Limit override of client %s expired (%s)", clientID, active.override.Reason)
+	if hook != nil {
+		if err := hook(clientID, active.override, nil); err != nil {
+			log.Printf("Failed to record expiry of the limit override of client %s: %v", clientID, err)
+		}
+	}
+}
+
+// currentOverride returns a client's override, or nil if it has none. Must be
+// called with the mutex held.
+func (m *Manager) currentOverride(clientID string) *types.LimitOverride {
+	if active, exists := m.overrides[clientID]; exists {
+		return active.override
+	}
+	return nil
+}
+
+// dropOverride removes a client's override and stops its timer. Must be called
+// with the mutex held.
+func (m *Manager) dropOverride(clientID string) {
+	if active, exists := m.overrides[clientID]; exists {
+		active.timer.Stop()
+		delete(m.overrides, clientID)
+	}
+}
+
+// overrideLimit applies an override's replacement or delta to a limit. A
+// delta never lowers a limit below 1 and does not create a limit.
+func overrideLimit(limit, replacement *int64, delta int64) *int64 {
+	switch {
+	case replacement != nil:
+		return replacement
+	case limit == nil || delta == 0:
+		return limit
+	}
+	adjusted := max(*limit+delta, 1)
+	return &adjusted
+}
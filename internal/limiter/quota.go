@@ -0,0 +1,154 @@
+package limiter
+
+import (
+	"time"
+
+	"flowguard/internal/types"
+)
+
+// Quota is the result of checking or consuming part of a client's quota
+type Quota struct {
+	Allowed    bool
+	Err        error         // Why the quota was denied: types.ErrRPMExceeded, ErrTPMExceeded or ErrUpstreamSaturated
+	Shadow     error         // Why the quota would have been denied if the client were not in shadow mode
+	RetryAfter time.Duration // When the denied amount will be available (0 if allowed or never)
+	Limits     []LimitStatus // State of the client's limits after the call
+}
+
+// CheckQuota reports whether a client could make the given number of
+// requests using the given number of tokens, without consuming anything.
+// Clients in shadow mode are always allowed, with Shadow set if they would not be.
+// Unknown clients are not created, and the adaptive upstream limit is not checked.
+func (m *Manager) CheckQuota(clientID string, requests, tokens int64) Quota {
+	resolvedID, client, exists := m.lookupClient(clientID)
+	if !exists && m.rejectsClient(clientID) {
+		return Quota{Err: types.ErrUnknownClient}
+	}
+
+	quota := Quota{Allowed: true}
+	if exists && client.config.EnforcementMode() != types.ModeOff {
+		rpmBucket, tpmBucket := client.buckets()
+		if rpmBucket != nil {
+			if ok, wait := rpmBucket.CanConsume(requests); !ok {
+				quota = Quota{Err: types.ErrRPMExceeded, RetryAfter: wait}
+			}
+		}
+		if quota.Allowed && tpmBucket != nil {
+			if ok, wait := tpmBucket.CanConsume(tokens); !ok {
+				quota = Quota{Err: types.ErrTPMExceeded, RetryAfter: wait}
+			}
+		}
+		if !quota.Allowed && client.config.EnforcementMode() == types.ModeShadow {
+			quota = Quota{Allowed: true, Shadow: quota.Err}
+		}
+	}
+
+	quota.Limits = m.GetLimitStatus(resolvedID)
+	return quota
+}
+
+// ConsumeQuota checks a client's quota for the given number of requests and
+// tokens and consumes it if allowed. Both limits are consumed or neither is.
+// Clients in shadow mode are always allowed, with Shadow set if they would not be.
+// Unknown clients are handled by the unknown client policy, as for proxied requests.
+func (m *Manager) ConsumeQuota(clientID string, requests, tokens int64) Quota {
+	clientID, client, err := m.resolveClient(clientID)
+	if err != nil {
+		return Quota{Err: err}
+	}
+
+	client.mutex.RLock()
+	config := client.config
+	rpmBucket, tpmBucket := client.rpmBucket, client.tpmBucket
+	client.mutex.RUnlock()
+
+	mode := config.EnforcementMode()
+	if mode == types.ModeOff {
+		// Rate limiting, including global admission, disabled for this client
+		m.updateSuccessStats(clientID, tokens)
+		return Quota{Allowed: true}
+	}
+
+	// Check the client's own limits before global admission, so requests
+	// they drop do not use up the upstream provider's capacity. Shadow mode
+	// forwards those requests anyway, so they are still admitted.
+	reason, wait := consumeBuckets(rpmBucket, tpmBucket, requests, tokens)
+	if adaptive := m.AdaptiveLimiter(); adaptive != nil && (reason == "" || mode == types.ModeShadow) {
+		if !adaptive.Admit(tokens) && reason == "" {
+			// Return the client's quota, as the request is refused
+			refundBuckets(rpmBucket, tpmBucket, requests, tokens)
+			reason, wait = "upstream", 0
+		}
+	}
+
+	switch {
+	case reason == "":
+		m.updateSuccessStats(clientID, tokens)
+		return Quota{Allowed: true, Limits: m.GetLimitStatus(clientID)}
+	case mode == types.ModeShadow:
+		// The buckets were left as enforcement would have left them, so
+		// shadow drops match what enforcing the limits would drop
+		m.updateShadowStats(clientID, tokens, reason)
+		return Quota{Allowed: true, Shadow: dropErrors[reason], Limits: m.GetLimitStatus(clientID)}
+	}
+
+	m.updateDroppedStats(clientID, reason)
+	return Quota{Err: dropErrors[reason], RetryAfter: wait, Limits: m.GetLimitStatus(clientID)}
+}
+
+// dropErrors are the errors of requests dropped for exceeding each limit
+var dropErrors = map[string]error{
+	"rpm":      types.ErrRPMExceeded,
+	"tpm":      types.ErrTPMExceeded,
+	"upstream": types.ErrUpstreamSaturated,
+}
+
+// consumeBuckets consumes requests and tokens from a client's buckets if
+// both allow it. Otherwise it consumes nothing and returns the exceeded
+// limit, rpm or tpm, and how long until the amount will be available.
+func consumeBuckets(rpmBucket, tpmBucket *types.TokenBucket, requests, tokens int64) (string, time.Duration) {
+	// Check RPM limit
+	if rpmBucket != nil {
+		if !rpmBucket.TryConsume(requests) {
+			_, wait := rpmBucket.CanConsume(requests)
+			return "rpm", wait
+		}
+	}
+
+	// Check TPM limit
+	if tpmBucket != nil {
+		if !tpmBucket.TryConsume(tokens) {
+			// Return the requests consumed above, since the call is rejected
+			if rpmBucket != nil {
+				rpmBucket.Refund(requests)
+			}
+			_, wait := tpmBucket.CanConsume(tokens)
+			return "tpm", wait
+		}
+	}
+	return "", 0
+}
+
+// refundBuckets returns requests and tokens to a client's buckets
+func refundBuckets(rpmBucket, tpmBucket *types.TokenBucket, requests, tokens int64) {
+	if rpmBucket != nil && requests > 0 {
+		rpmBucket.Refund(requests)
+	}
+	if tpmBucket != nil && tokens > 0 {
+		tpmBucket.Refund(tokens)
+	}
+}
+
+// RefundQuota returns requests and tokens consumed by a client that were not
+// used, for example when a call failed or used fewer tokens than estimated.
+// Limits are not refilled beyond their capacity.
+func (m *Manager) RefundQuota(clientID string, requests, tokens int64) ([]LimitStatus, error) {
+	clientID, client, exists := m.lookupClient(clientID)
+	if !exists {
+		return nil, types.ErrClientNotFound
+	}
+
+	rpmBucket, tpmBucket := client.buckets()
+	refundBuckets(rpmBucket, tpmBucket, requests, tokens)
+	return m.GetLimitStatus(clientID), nil
+}
@@ -0,0 +1,336 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"flowguard/internal/types"
+)
+
+// Policies for requests from client IDs that are not configured
+const (
+	UnknownClientCreate  = "create"  // Create the client from the template
+	UnknownClientDefault = "default" // Count the request against the shared default client
+	UnknownClientReject  = "reject"  // Reject the request with types.ErrUnknownClient
+)
+
+// Reasons auto-created clients are evicted
+const (
+	EvictCapacity = "capacity"
+	EvictIdle     = "idle"
+	EvictRule     = "rule" // The client rule it was created for changed or was deleted
+)
+
+// maxSweepInterval is the longest time between sweeps for idle auto-created clients
+const maxSweepInterval = time.Minute
+
+// RegistryConfig controls how requests from unconfigured client IDs are
+// handled and how many clients are created for them
+type RegistryConfig struct {
+	UnknownClientPolicy string        // UnknownClientCreate if empty
+	DefaultTemplate     string        // Limit template of auto-created clients and the default client; empty for no limits
+	DefaultClientID     string        // Client shared under UnknownClientDefault
+	MaxAutoClients      int           // Auto-created clients kept before the least recently used is evicted; 0 for no cap
+	IdleTTL             time.Duration // Auto-created clients unused for this long are evicted; 0 keeps them
+}
+
+// Validate checks a registry configuration
+func (c RegistryConfig) Validate() error {
+	switch c.UnknownClientPolicy {
+	case "", UnknownClientCreate, UnknownClientReject:
+	case UnknownClientDefault:
+		if err := (&types.ClientConfig{ClientID: c.DefaultClientID}).Validate(); err != nil {
+			return fmt.Errorf("invalid default client ID %q: %w", c.DefaultClientID, err)
+		}
+	default:
+		return fmt.Errorf("unknown client policy %q (want create, default or reject)", c.UnknownClientPolicy)
+	}
+
+	if c.MaxAutoClients < 0 {
+		return errors.New("max auto-created clients must not be negative")
+	}
+	if c.IdleTTL < 0 {
+		return errors.New("idle TTL must not be negative")
+	}
+	return nil
+}
+
+// autoClient tracks when an auto-created client was last used
+type autoClient struct {
+	clientID string
+	lastUsed time.Time
+}
+
+// errClientExists abandons the creation of a client that was configured concurrently
+var errClientExists = errors.New("client already exists")
+
+// SetRegistryConfig sets how unconfigured client IDs are handled. The
+// default template must already exist. Clients already created are evicted
+// if they are over the new cap.
+func (m *Manager) SetRegistryConfig(config RegistryConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	if _, exists := m.templates[config.DefaultTemplate]; config.DefaultTemplate != "" && !exists {
+		m.mutex.Unlock()
+		return fmt.Errorf("default template %q does not exist", config.DefaultTemplate)
+	}
+	m.registry = config
+	m.mutex.Unlock()
+
+	m.evictAutoClients()
+	return nil
+}
+
+// AutoClientCount returns the number of auto-created clients
+func (m *Manager) AutoClientCount() int {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.autoClients.Len()
+}
+
+// ResolveClientID returns the ID of the client whose limits and statistics
+// apply to requests from clientID: the shared default client for unknown
+// IDs under UnknownClientDefault, and clientID otherwise
+func (m *Manager) ResolveClientID(clientID string) string {
+	if resolvedID, _, exists := m.lookupClient(clientID); exists {
+		return resolvedID
+	}
+	return clientID
+}
+
+// StartEviction periodically evicts idle auto-created clients until ctx is
+// done. It does nothing unless an idle TTL is configured.
+func (m *Manager) StartEviction(ctx context.Context) {
+	m.mutex.RLock()
+	interval := m.registry.IdleTTL
+	m.mutex.RUnlock()
+	if interval <= 0 {
+		return
+	}
+	if interval > maxSweepInterval {
+		interval = maxSweepInterval
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.evictAutoClients()
+			}
+		}
+	}()
+}
+
+// rejectsClient reports whether requests from clientID are rejected because
+// it is not configured and matches no client rule
+func (m *Manager) rejectsClient(clientID string) bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.registry.UnknownClientPolicy == UnknownClientReject && m.matchRule(clientID) == nil
+}
+
+// lookupClient returns the ID and limiter of the client whose limits apply to
+// requests from clientID, without creating any client
+func (m *Manager) lookupClient(clientID string) (string, *ClientLimiter, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if client, exists := m.clients[clientID]; exists {
+		return clientID, client, true
+	}
+	if rule := m.matchRule(clientID); rule != nil {
+		if client, exists := m.clients[rule.Name]; exists && rule.Shared {
+			return rule.Name, client, true
+		}
+		return "", nil, false
+	}
+	if m.registry.UnknownClientPolicy == UnknownClientDefault {
+		if client, exists := m.clients[m.registry.DefaultClientID]; exists {
+			return m.registry.DefaultClientID, client, true
+		}
+	}
+	return "", nil, false
+}
+
+// resolveClient returns the ID and limiter of the client whose limits apply
+// to requests from clientID, applying the first matching client rule or the
+// unknown client policy if it is not configured, and initializes its
+// statistics. It fails with a *types.ValidationError if the client ID is not
+// valid, or with types.ErrUnknownClient if unknown clients are rejected.
+func (m *Manager) resolveClient(clientID string) (string, *ClientLimiter, error) {
+	m.mutex.RLock()
+	client, exists := m.clients[clientID]
+	m.mutex.RUnlock()
+
+	if exists {
+		if client.config.AutoCreated {
+			m.touchAutoClient(clientID)
+		}
+		m.ensureStats(clientID)
+		return clientID, client, nil
+	}
+
+	if err := (&types.ClientConfig{ClientID: clientID}).Validate(); err != nil {
+		return "", nil, err
+	}
+
+	m.mutex.RLock()
+	registry := m.registry
+	rule := m.matchRule(clientID)
+	if named, exists := m.rules[clientID]; exists && named.Shared {
+		// The ID of a group client counts against the group, as it does once the group client exists
+		rule = named
+	}
+	m.mutex.RUnlock()
+
+	origin := clientOrigin{auto: true}
+	template := registry.DefaultTemplate
+	switch {
+	case rule != nil && rule.Shared:
+		// The group client is created once and kept until the rule changes
+		clientID = rule.Name
+		origin = clientOrigin{rule: rule.Name}
+		template = ""
+	case rule != nil:
+		origin.rule = rule.Name
+		template = ""
+	case registry.UnknownClientPolicy == UnknownClientReject:
+		return "", nil, types.ErrUnknownClient
+	case registry.UnknownClientPolicy == UnknownClientDefault:
+		// The shared client is created once and never evicted
+		clientID = registry.DefaultClientID
+		origin = clientOrigin{}
+	}
+
+	_, err := m.updateClient(clientID, 0, func(current *types.ClientConfig) (*types.ClientConfig, error) {
+		if current != nil {
+			return nil, errClientExists
+		}
+		return &types.ClientConfig{
+			ClientID: clientID,
+			Template: template,
+			Enabled:  true,
+		}, nil
+	}, nil, origin)
+	if err != nil && !errors.Is(err, errClientExists) {
+		return "", nil, err
+	}
+	if origin.auto {
+		m.evictAutoClients()
+	}
+
+	m.mutex.RLock()
+	client, exists = m.clients[clientID]
+	m.mutex.RUnlock()
+	if !exists {
+		// Deleted or evicted again before it could be used
+		return "", nil, types.ErrClientNotFound
+	}
+	m.ensureStats(clientID)
+	return clientID, client, nil
+}
+
+// ensureStats initializes a client's statistics if they do not exist
+func (m *Manager) ensureStats(clientID string) {
+	m.mutex.RLock()
+	_, exists := m.stats[clientID]
+	m.mutex.RUnlock()
+	if exists {
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, exists := m.stats[clientID]; !exists {
+		m.stats[clientID] = &types.ClientStats{
+			ClientID:        clientID,
+			LastRequestTime: time.Now(),
+		}
+	}
+}
+
+// touchAutoClient marks an auto-created client as the most recently used
+func (m *Manager) touchAutoClient(clientID string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if element, exists := m.autoIndex[clientID]; exists {
+		element.Value.(*autoClient).lastUsed = time.Now()
+		m.autoClients.MoveToFront(element)
+	}
+}
+
+// trackAutoClient starts tracking the use of an auto-created client, or
+// stops tracking it if auto is false. Must be called with the mutex held.
+func (m *Manager) trackAutoClient(clientID string, auto bool) {
+	element, tracked := m.autoIndex[clientID]
+	switch {
+	case auto && !tracked:
+		m.autoIndex[clientID] = m.autoClients.PushFront(&autoClient{clientID: clientID, lastUsed: time.Now()})
+	case !auto && tracked:
+		m.autoClients.Remove(element)
+		delete(m.autoIndex, clientID)
+	}
+}
+
+// evictAutoClients evicts the least recently used auto-created clients over
+// the cap and those idle for longer than the TTL
+func (m *Manager) evictAutoClients() {
+	type eviction struct{ clientID, reason string }
+	var evicted []eviction
+
+	m.mutex.Lock()
+	now := time.Now()
+	for element := m.autoClients.Back(); element != nil; element = m.autoClients.Back() {
+		entry := element.Value.(*autoClient)
+
+		reason := ""
+		switch {
+		case m.registry.MaxAutoClients > 0 && m.autoClients.Len() > m.registry.MaxAutoClients:
+			reason = EvictCapacity
+		case m.registry.IdleTTL > 0 && now.Sub(entry.lastUsed) >= m.registry.IdleTTL:
+			reason = EvictIdle
+		}
+		if reason == "" {
+			// Clients further forward were used more recently
+			break
+		}
+
+		m.evictClient(entry.clientID, now)
+		evicted = append(evicted, eviction{entry.clientID, reason})
+	}
+	observer := m.observer
+	m.mutex.Unlock()
+
+	if observer != nil {
+		for _, e := range evicted {
+			observer.ClientEvicted(e.clientID, e.reason)
+		}
+	}
+}
+
+// evictClient removes a client created by the manager. Its history is dropped
+// too unless it was ever configured through the API. Must be called with the mutex held.
+func (m *Manager) evictClient(clientID string, now time.Time) {
+	m.removeClient(clientID)
+
+	configured := false
+	for _, past := range m.history[clientID] {
+		configured = configured || (!past.AutoCreated && past.Rule == "")
+	}
+	if !configured {
+		delete(m.history, clientID)
+	}
+
+	m.publishConfig(ConfigEvent{Type: ConfigEvicted, ClientID: clientID, Time: now.UTC()})
+}
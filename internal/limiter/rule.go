@@ -0,0 +1,215 @@
+package limiter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"time"
+
+	"flowguard/internal/types"
+)
+
+// ErrRuleNotFound is returned for operations on a client rule that does not exist
+var ErrRuleNotFound = errors.New("client rule not found")
+
+// clientRule is a client rule with its compiled pattern
+type clientRule struct {
+	*types.ClientRule
+	regex *regexp.Regexp // nil for glob patterns
+}
+
+// matches reports whether a client ID matches the rule's pattern
+func (r *clientRule) matches(clientID string) bool {
+	if r.regex != nil {
+		return r.regex.MatchString(clientID)
+	}
+	matched, _ := path.Match(r.Pattern, clientID)
+	return matched
+}
+
+// LoadRules reads client rules from a JSON file holding an array of rules
+func LoadRules(path string) ([]*types.ClientRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client rules: %w", err)
+	}
+
+	var rules []*types.ClientRule
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&rules); err != nil {
+		return nil, fmt.Errorf("failed to parse client rules: %w", err)
+	}
+	return rules, nil
+}
+
+// RuleHook is called with a client rule before and after a change, with the
+// manager locked just before the change is applied, so it must not block;
+// before is nil when the rule is created and after is nil when it is
+// deleted. Returning an error rejects the change.
+type RuleHook func(before, after *types.ClientRule) error
+
+// SetRule creates or replaces a client rule and returns the stored rule.
+// If only its limits change, clients created for it keep their buckets;
+// if its pattern, or whether the bucket is shared, changes they are evicted
+// and matched again on their next request. Invalid rules, and shared rules
+// named after an existing client, return a *types.ValidationError.
+func (m *Manager) SetRule(rule *types.ClientRule, hook RuleHook) (*types.ClientRule, error) {
+	if err := rule.Validate(); err != nil {
+		return nil, err
+	}
+	stored := rule.Clone()
+	stored.UpdatedAt = time.Now().UTC()
+
+	compiled := &clientRule{ClientRule: stored}
+	if stored.Regex {
+		compiled.regex = regexp.MustCompile(`^(?:` + stored.Pattern + `)$`)
+	}
+
+	m.mutex.Lock()
+	if _, exists := m.templates[stored.Template]; stored.Template != "" && !exists {
+		m.mutex.Unlock()
+		return nil, types.NewValidationError("template", "must name an existing limit template")
+	}
+	if client, exists := m.clients[stored.Name]; exists && stored.Shared && client.config.Rule != stored.Name {
+		// The group client of a shared rule is named after it
+		m.mutex.Unlock()
+		return nil, types.NewValidationError("name", "must not be the ID of an existing client when shared")
+	}
+
+	previous, replaced := m.rules[stored.Name]
+	if hook != nil {
+		var before *types.ClientRule
+		if replaced {
+			before = previous.ClientRule
+		}
+		if err := hook(before, stored); err != nil {
+			m.mutex.Unlock()
+			return nil, err
+		}
+	}
+	m.rules[stored.Name] = compiled
+	m.sortRules()
+
+	var evicted []string
+	if replaced && (previous.Pattern != stored.Pattern || previous.Regex != stored.Regex || previous.Shared != stored.Shared) {
+		evicted = m.evictRuleClients(stored.Name)
+	} else {
+		for _, client := range m.clients {
+			if client.config.Rule == stored.Name {
+				client.setLimits(m.effectiveLimits(client, stored.UpdatedAt))
+			}
+		}
+	}
+	observer := m.observer
+	m.mutex.Unlock()
+
+	notifyEvicted(observer, evicted)
+	return stored, nil
+}
+
+// GetRule returns a client rule
+func (m *Manager) GetRule(name string) (*types.ClientRule, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	rule, exists := m.rules[name]
+	if !exists {
+		return nil, false
+	}
+	return rule.ClientRule, true
+}
+
+// ListRules returns all client rules in the order they are evaluated
+func (m *Manager) ListRules() []*types.ClientRule {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	rules := make([]*types.ClientRule, 0, len(m.ruleOrder))
+	for _, rule := range m.ruleOrder {
+		rules = append(rules, rule.ClientRule)
+	}
+	return rules
+}
+
+// DeleteRule removes a client rule and evicts the clients created for it
+func (m *Manager) DeleteRule(name string, hook RuleHook) error {
+	m.mutex.Lock()
+	rule, exists := m.rules[name]
+	if !exists {
+		m.mutex.Unlock()
+		return ErrRuleNotFound
+	}
+	if hook != nil {
+		if err := hook(rule.ClientRule, nil); err != nil {
+			m.mutex.Unlock()
+			return err
+		}
+	}
+
+	delete(m.rules, name)
+	m.sortRules()
+	evicted := m.evictRuleClients(name)
+	observer := m.observer
+	m.mutex.Unlock()
+
+	notifyEvicted(observer, evicted)
+	return nil
+}
+
+// matchRule returns the first rule, in priority order, matching a client ID,
+// or nil if none does. Must be called with the mutex held.
+func (m *Manager) matchRule(clientID string) *clientRule {
+	for _, rule := range m.ruleOrder {
+		if rule.matches(clientID) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// sortRules orders the rules by priority, then name. Must be called with the mutex held.
+func (m *Manager) sortRules() {
+	m.ruleOrder = m.ruleOrder[:0]
+	for _, rule := range m.rules {
+		m.ruleOrder = append(m.ruleOrder, rule)
+	}
+	sort.Slice(m.ruleOrder, func(i, j int) bool {
+		if m.ruleOrder[i].Priority != m.ruleOrder[j].Priority {
+			return m.ruleOrder[i].Priority < m.ruleOrder[j].Priority
+		}
+		return m.ruleOrder[i].Name < m.ruleOrder[j].Name
+	})
+}
+
+// evictRuleClients removes the clients created for a rule and returns their
+// IDs. Must be called with the mutex held.
+func (m *Manager) evictRuleClients(name string) []string {
+	now := time.Now()
+
+	var evicted []string
+	for clientID, client := range m.clients {
+		if client.config.Rule == name {
+			evicted = append(evicted, clientID)
+		}
+	}
+	for _, clientID := range evicted {
+		m.evictClient(clientID, now)
+	}
+	return evicted
+}
+
+// notifyEvicted reports clients evicted for a rule change to the observer
+func notifyEvicted(observer Observer, clientIDs []string) {
+	if observer == nil {
+		return
+	}
+	for _, clientID := range clientIDs {
+		observer.ClientEvicted(clientID, EvictRule)
+	}
+}
@@ -0,0 +1,121 @@
+package limiter
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"flowguard/internal/types"
+)
+
+// compiledSchedule is a validated schedule ready to be evaluated
+type compiledSchedule struct {
+	location *time.Location
+	windows  []compiledWindow
+}
+
+// compiledWindow is a schedule window with its days and times parsed
+type compiledWindow struct {
+	types.ScheduleWindow
+	days  [7]bool // Indexed by time.Weekday
+	start int     // Minutes after midnight
+	end   int
+}
+
+// compileSchedule prepares a schedule for evaluation. It returns nil for no
+// schedule. The schedule must have been validated.
+func compileSchedule(schedule *types.Schedule) *compiledSchedule {
+	if schedule == nil {
+		return nil
+	}
+
+	location, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		location = time.UTC
+	}
+
+	compiled := &compiledSchedule{location: location}
+	for _, window := range schedule.Windows {
+		w := compiledWindow{ScheduleWindow: window}
+		w.start, _ = types.ParseTimeOfDay(window.Start)
+		w.end, _ = types.ParseTimeOfDay(window.End)
+		for _, day := range window.Days {
+			if weekday, ok := types.Weekday(day); ok {
+				w.days[weekday] = true
+			}
+		}
+		if len(window.Days) == 0 {
+			w.days = [7]bool{true, true, true, true, true, true, true}
+		}
+		compiled.windows = append(compiled.windows, w)
+	}
+	return compiled
+}
+
+// active returns the first window containing a time, or nil if none does
+// or there is no schedule. A window crossing midnight belongs to the day it starts.
+func (s *compiledSchedule) active(now time.Time) *compiledWindow {
+	if s == nil {
+		return nil
+	}
+
+	local := now.In(s.location)
+	minute := local.Hour()*60 + local.Minute()
+	today := local.Weekday()
+	yesterday := (today + 6) % 7
+
+	for i := range s.windows {
+		w := &s.windows[i]
+		if w.start < w.end {
+			if w.days[today] && minute >= w.start && minute < w.end {
+				return w
+			}
+		} else if (w.days[today] && minute >= w.start) || (w.days[yesterday] && minute < w.end) {
+			return w
+		}
+	}
+	return nil
+}
+
+// StartScheduler moves clients with a schedule between its windows until ctx
+// is done. Windows begin and end on minute boundaries, so schedules are
+// evaluated at the start of every minute; buckets keep their levels across
+// a transition.
+func (m *Manager) StartScheduler(ctx context.Context) {
+	go func() {
+		for {
+			now := time.Now()
+			timer := time.NewTimer(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case now = <-timer.C:
+			}
+			m.applySchedules(now)
+		}
+	}()
+}
+
+// applySchedules updates the limits of clients whose active schedule window changed
+func (m *Manager) applySchedules(now time.Time) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	for clientID, client := range m.clients {
+		if client.schedule == nil {
+			continue
+		}
+
+		limits := m.effectiveLimits(client, now)
+		client.mutex.RLock()
+		previous := client.limits.ScheduleWindow
+		client.mutex.RUnlock()
+		if limits.ScheduleWindow == previous {
+			continue
+		}
+
+		client.setLimits(limits)
+		log.Printf("Client %s moved from schedule window %q to %q", clientID, previous, limits.ScheduleWindow)
+	}
+}
@@ -0,0 +1,130 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+
+	"flowguard/internal/types"
+)
+
+func int64Ptr(v int64) *int64 { return &v }
+
+// testSchedule has an overnight window starting on Sundays, a weekday window
+// and an every-day window crossing midnight
+var testSchedule = &types.Schedule{
+	Windows: []types.ScheduleWindow{
+		{Name: "sunday-night", Days: []string{"sun"}, Start: "22:00", End: "06:00", RPM: int64Ptr(5)},
+		{Name: "office", Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "09:00", End: "17:00", RPM: int64Ptr(50)},
+		{Name: "late", Start: "23:30", End: "00:30", RPM: int64Ptr(1)},
+	},
+}
+
+func TestScheduleActive(t *testing.T) {
+	// 2026-10-18 is a Sunday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 10, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want string // Empty for no window
+	}{
+		{"before overnight window", at(18, 21, 59), ""},
+		{"overnight window start", at(18, 22, 0), "sunday-night"},
+		{"overnight window before midnight", at(18, 23, 0), "sunday-night"},
+		{"overnight window after midnight", at(19, 0, 0), "sunday-night"},
+		{"overnight window last minute", at(19, 5, 59), "sunday-night"},
+		{"overnight window end", at(19, 6, 0), ""},
+		{"overnight window on another day", at(17, 23, 0), ""},
+		{"overnight window spills into another day", at(21, 1, 0), ""},
+		{"weekday window", at(19, 9, 0), "office"},
+		{"weekday window end", at(19, 17, 0), ""},
+		{"weekday window on a weekend", at(17, 10, 0), ""},
+		{"every day window before midnight", at(20, 23, 45), "late"},
+		{"every day window after midnight", at(21, 0, 15), "late"},
+		{"every day window end", at(21, 0, 30), ""},
+		{"first matching window wins", at(18, 23, 45), "sunday-night"},
+	}
+
+	schedule := compileSchedule(testSchedule)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			if window := schedule.active(tt.now); window != nil {
+				got = window.Name
+			}
+			if got != tt.want {
+				t.Errorf("active(%s) = %q, want %q", tt.now.Format(time.RFC3339), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduleActiveTimezone(t *testing.T) {
+	schedule := compileSchedule(&types.Schedule{
+		Timezone: "America/New_York",
+		Windows: []types.ScheduleWindow{
+			{Name: "office", Days: []string{"mon"}, Start: "09:00", End: "17:00"},
+			{Name: "sunday-night", Days: []string{"sun"}, Start: "22:00", End: "06:00"},
+		},
+	})
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want string
+	}{
+		// New York is UTC-4 in October
+		{"before local start", time.Date(2026, 10, 19, 12, 59, 0, 0, time.UTC), ""},
+		{"local start", time.Date(2026, 10, 19, 13, 0, 0, 0, time.UTC), "office"},
+		{"local end", time.Date(2026, 10, 19, 21, 0, 0, 0, time.UTC), ""},
+		// Monday 01:00 UTC is still Sunday 21:00 in New York
+		{"local day differs from UTC", time.Date(2026, 10, 19, 1, 0, 0, 0, time.UTC), ""},
+		{"overnight window in local time", time.Date(2026, 10, 19, 2, 0, 0, 0, time.UTC), "sunday-night"},
+		{"overnight window after local midnight", time.Date(2026, 10, 19, 9, 59, 0, 0, time.UTC), "sunday-night"},
+		{"other time zone input", time.Date(2026, 10, 19, 15, 0, 0, 0, time.FixedZone("CEST", 2*60*60)), "office"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			if window := schedule.active(tt.now); window != nil {
+				got = window.Name
+			}
+			if got != tt.want {
+				t.Errorf("active(%s) = %q, want %q", tt.now.Format(time.RFC3339), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplySchedules(t *testing.T) {
+	m := NewManager()
+	if err := m.SetClientConfig(&types.ClientConfig{ClientID: "scheduled", RPM: int64Ptr(100), Schedule: testSchedule, Enabled: true}); err != nil {
+		t.Fatalf("SetClientConfig: %v", err)
+	}
+
+	steps := []struct {
+		now        time.Time
+		wantWindow string
+		wantRPM    int64
+	}{
+		{time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC), "", 100},
+		{time.Date(2026, 10, 18, 22, 0, 0, 0, time.UTC), "sunday-night", 5},
+		{time.Date(2026, 10, 19, 3, 0, 0, 0, time.UTC), "sunday-night", 5},
+		{time.Date(2026, 10, 19, 6, 0, 0, 0, time.UTC), "", 100},
+		{time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC), "office", 50},
+		{time.Date(2026, 10, 19, 23, 30, 0, 0, time.UTC), "late", 1},
+		{time.Date(2026, 10, 20, 0, 30, 0, 0, time.UTC), "", 100},
+	}
+
+	for _, step := range steps {
+		m.applySchedules(step.now)
+		limits, _ := m.GetEffectiveLimits("scheduled")
+		if limits.ScheduleWindow != step.wantWindow || limits.RPM == nil || *limits.RPM != step.wantRPM {
+			t.Errorf("at %s: window %q, rpm %v; want window %q, rpm %d",
+				step.now.Format(time.RFC3339), limits.ScheduleWindow, limits.RPM, step.wantWindow, step.wantRPM)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package limiter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"flowguard/internal/types"
+)
+
+// ErrTemplateNotFound is returned for operations on a limit template that does not exist
+var ErrTemplateNotFound = errors.New("limit template not found")
+
+// TemplateInUseError is returned when deleting a limit template that is still needed
+type TemplateInUseError struct {
+	Name    string
+	Clients int  // Clients referencing the template
+	Rules   int  // Client rules referencing the template
+	Default bool // Whether it is the default template for unknown clients
+}
+
+func (e *TemplateInUseError) Error() string {
+	if e.Default {
+		return fmt.Sprintf("template %s is the default template for unknown clients", e.Name)
+	}
+	return fmt.Sprintf("template %s is referenced by %d clients and %d client rules", e.Name, e.Clients, e.Rules)
+}
+
+// DefaultTemplates returns the limit templates created at startup when no
+// templates file is given
+func DefaultTemplates() []*types.LimitTemplate {
+	limit := func(perMinute int64) *int64 { return &perMinute }
+	return []*types.LimitTemplate{
+		{Name: "free", RPM: limit(10), TPM: limit(10000)},
+		{Name: "standard", RPM: limit(60), TPM: limit(100000)},
+	}
+}
+
+// LoadTemplates reads limit templates from a JSON file holding an array of templates
+func LoadTemplates(path string) ([]*types.LimitTemplate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read templates: %w", err)
+	}
+
+	var templates []*types.LimitTemplate
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&templates); err != nil {
+		return nil, fmt.Errorf("failed to parse templates: %w", err)
+	}
+	return templates, nil
+}
+
+// TemplateHook is called with a limit template before and after a change,
+// with the manager locked just before the change is applied, so it must not
+// block; before is nil when the template is created and after is nil when it
+// is deleted. Returning an error rejects the change.
+type TemplateHook func(before, after *types.LimitTemplate) error
+
+// SetTemplate creates or replaces a limit template and returns the stored
+// template with the number of clients whose limits changed with it. Clients
+// referencing the template keep their bucket levels. Invalid templates
+// return a *types.ValidationError.
+func (m *Manager) SetTemplate(template *types.LimitTemplate, hook TemplateHook) (*types.LimitTemplate, int, error) {
+	if err := template.Validate(); err != nil {
+		return nil, 0, err
+	}
+	stored := template.Clone()
+	stored.UpdatedAt = time.Now().UTC()
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if hook != nil {
+		if err := hook(m.templates[stored.Name], stored); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	m.templates[stored.Name] = stored
+
+	updated := 0
+	for _, client := range m.clients {
+		if m.usesTemplate(client.config, stored.Name) {
+			client.setLimits(m.effectiveLimits(client, stored.UpdatedAt))
+			updated++
+		}
+	}
+	return stored, updated, nil
+}
+
+// GetTemplate returns a limit template
+func (m *Manager) GetTemplate(name string) (*types.LimitTemplate, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	template, exists := m.templates[name]
+	return template, exists
+}
+
+// ListTemplates returns all limit templates, sorted by name
+func (m *Manager) ListTemplates() []*types.LimitTemplate {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	templates := make([]*types.LimitTemplate, 0, len(m.templates))
+	for _, template := range m.templates {
+		templates = append(templates, template)
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Name < templates[j].Name
+	})
+	return templates
+}
+
+// DeleteTemplate removes a limit template. It fails with a
+// *TemplateInUseError if clients or client rules reference it, or it is the
+// default template.
+func (m *Manager) DeleteTemplate(name string, hook TemplateHook) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	template, exists := m.templates[name]
+	if !exists {
+		return ErrTemplateNotFound
+	}
+	if m.registry.DefaultTemplate == name {
+		return &TemplateInUseError{Name: name, Default: true}
+	}
+
+	inUse := &TemplateInUseError{Name: name}
+	for _, client := range m.clients {
+		if client.config.Template == name {
+			inUse.Clients++
+		}
+	}
+	for _, rule := range m.rules {
+		if rule.Template == name {
+			inUse.Rules++
+		}
+	}
+	if inUse.Clients > 0 || inUse.Rules > 0 {
+		return inUse
+	}
+	if hook != nil {
+		if err := hook(template, nil); err != nil {
+			return err
+		}
+	}
+
+	delete(m.templates, name)
+	return nil
+}
+
+// effectiveLimits returns the limits enforced for a client at a time: those
+// of its active schedule window, then its own, with those left unset taken
+// from the rule it was created for, then from its template or the rule's,
+// and finally adjusted by its override. Must be called with the mutex held.
+func (m *Manager) effectiveLimits(client *ClientLimiter, now time.Time) types.Limits {
+	config := client.config
+	limits := types.Limits{RPM: config.RPM, TPM: config.TPM}
+	if window := client.schedule.active(now); window != nil {
+		limits.ScheduleWindow = window.Name
+		if window.RPM != nil {
+			limits.RPM = window.RPM
+		}
+		if window.TPM != nil {
+			limits.TPM = window.TPM
+		}
+	}
+
+	templateName := config.Template
+	if rule, exists := m.rules[config.Rule]; exists {
+		limits = fillLimits(limits, rule.RPM, rule.TPM)
+		if templateName == "" {
+			templateName = rule.Template
+		}
+	}
+	if template, exists := m.templates[templateName]; exists {
+		limits = fillLimits(limits, template.RPM, template.TPM)
+	}
+	if override := m.currentOverride(config.ClientID); override != nil {
+		limits.RPM = overrideLimit(limits.RPM, override.RPM, override.RPMDelta)
+		limits.TPM = overrideLimit(limits.TPM, override.TPM, override.TPMDelta)
+	}
+	return limits
+}
+
+// usesTemplate reports whether a configuration's limits come from a
+// template, directly or through its rule. Must be called with the mutex held.
+func (m *Manager) usesTemplate(config *types.ClientConfig, name string) bool {
+	if config.Template != "" {
+		return config.Template == name
+	}
+	rule, exists := m.rules[config.Rule]
+	return exists && rule.Template == name
+}
+
+// fillLimits sets the limits that are unset to rpm and tpm
+func fillLimits(limits types.Limits, rpm, tpm *int64) types.Limits {
+	if limits.RPM == nil {
+		limits.RPM = rpm
+	}
+	if limits.TPM == nil {
+		limits.TPM = tpm
+	}
+	return limits
+}
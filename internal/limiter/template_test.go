@@ -0,0 +1,162 @@
+package limiter
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"flowguard/internal/types"
+)
+
+func TestEffectiveLimitsPrecedence(t *testing.T) {
+	// Sunday 23:00 UTC, inside the window below
+	now := time.Date(2026, 10, 18, 23, 0, 0, 0, time.UTC)
+	window := func(rpm, tpm *int64) *types.Schedule {
+		return &types.Schedule{Windows: []types.ScheduleWindow{
+			{Name: "night", Days: []string{"sun"}, Start: "22:00", End: "06:00", RPM: rpm, TPM: tpm},
+		}}
+	}
+
+	tests := []struct {
+		name     string
+		config   types.ClientConfig
+		override *types.LimitOverride
+		wantRPM  *int64
+		wantTPM  *int64
+		window   string
+	}{
+		{
+			name:    "own limits",
+			config:  types.ClientConfig{RPM: int64Ptr(10), TPM: int64Ptr(1000)},
+			wantRPM: int64Ptr(10),
+			wantTPM: int64Ptr(1000),
+		},
+		{
+			name:    "no limits",
+			config:  types.ClientConfig{},
+			wantRPM: nil,
+			wantTPM: nil,
+		},
+		{
+			name:    "template fills unset limits",
+			config:  types.ClientConfig{Template: "tier", RPM: int64Ptr(10)},
+			wantRPM: int64Ptr(10),
+			wantTPM: int64Ptr(3000),
+		},
+		{
+			name:    "rule before its template",
+			config:  types.ClientConfig{Rule: "group"},
+			wantRPM: int64Ptr(20),
+			wantTPM: int64Ptr(3000),
+		},
+		{
+			name:    "own before rule",
+			config:  types.ClientConfig{Rule: "group", RPM: int64Ptr(10)},
+			wantRPM: int64Ptr(10),
+			wantTPM: int64Ptr(3000),
+		},
+		{
+			name:    "own template replaces the rule's",
+			config:  types.ClientConfig{Rule: "group", Template: "other"},
+			wantRPM: int64Ptr(20),
+			wantTPM: int64Ptr(4000),
+		},
+		{
+			name:    "schedule before own",
+			config:  types.ClientConfig{RPM: int64Ptr(10), TPM: int64Ptr(1000), Schedule: window(int64Ptr(5), int64Ptr(500))},
+			wantRPM: int64Ptr(5),
+			wantTPM: int64Ptr(500),
+			window:  "night",
+		},
+		{
+			name:    "unset window limits fall through to own",
+			config:  types.ClientConfig{RPM: int64Ptr(10), TPM: int64Ptr(1000), Schedule: window(int64Ptr(5), nil)},
+			wantRPM: int64Ptr(5),
+			wantTPM: int64Ptr(1000),
+			window:  "night",
+		},
+		{
+			name:    "unset window limits fall through to the template",
+			config:  types.ClientConfig{Template: "tier", Schedule: window(int64Ptr(5), nil)},
+			wantRPM: int64Ptr(5),
+			wantTPM: int64Ptr(3000),
+			window:  "night",
+		},
+		{
+			name:    "schedule before rule",
+			config:  types.ClientConfig{Rule: "group", Schedule: window(int64Ptr(5), nil)},
+			wantRPM: int64Ptr(5),
+			wantTPM: int64Ptr(3000),
+			window:  "night",
+		},
+		{
+			name:     "override replaces a limit",
+			config:   types.ClientConfig{Template: "tier", Schedule: window(int64Ptr(5), nil)},
+			override: &types.LimitOverride{RPM: int64Ptr(1), Reason: "incident"},
+			wantRPM:  int64Ptr(1),
+			wantTPM:  int64Ptr(3000),
+			window:   "night",
+		},
+		{
+			name:     "override adjusts inherited limits",
+			config:   types.ClientConfig{Rule: "group"},
+			override: &types.LimitOverride{RPMDelta: 5, TPMDelta: -1000, Reason: "launch"},
+			wantRPM:  int64Ptr(25),
+			wantTPM:  int64Ptr(2000),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewManager()
+			for _, template := range []*types.LimitTemplate{
+				{Name: "tier", RPM: int64Ptr(30), TPM: int64Ptr(3000)},
+				{Name: "other", RPM: int64Ptr(40), TPM: int64Ptr(4000)},
+			} {
+				if _, _, err := m.SetTemplate(template, nil); err != nil {
+					t.Fatalf("SetTemplate: %v", err)
+				}
+			}
+			if _, err := m.SetRule(&types.ClientRule{Name: "group", Pattern: "group-*", Template: "tier", RPM: int64Ptr(20)}, nil); err != nil {
+				t.Fatalf("SetRule: %v", err)
+			}
+
+			// Installed directly, as only the manager assigns a client's rule
+			config := tt.config
+			config.ClientID = "client"
+			config.Enabled = true
+			m.mutex.Lock()
+			m.installClient(&config)
+			m.mutex.Unlock()
+			if tt.override != nil {
+				if _, err := m.SetOverride("client", tt.override, time.Minute, nil); err != nil {
+					t.Fatalf("SetOverride: %v", err)
+				}
+			}
+
+			m.mutex.RLock()
+			limits := m.effectiveLimits(m.clients["client"], now)
+			m.mutex.RUnlock()
+
+			if !equalLimit(limits.RPM, tt.wantRPM) || !equalLimit(limits.TPM, tt.wantTPM) || limits.ScheduleWindow != tt.window {
+				t.Errorf("effectiveLimits = rpm %s, tpm %s, window %q; want rpm %s, tpm %s, window %q",
+					formatLimit(limits.RPM), formatLimit(limits.TPM), limits.ScheduleWindow,
+					formatLimit(tt.wantRPM), formatLimit(tt.wantTPM), tt.window)
+			}
+		})
+	}
+}
+
+func equalLimit(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func formatLimit(limit *int64) string {
+	if limit == nil {
+		return "unset"
+	}
+	return fmt.Sprint(*limit)
+}
@@ -0,0 +1,190 @@
+package limiter
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"flowguard/internal/types"
+)
+
+// watchBufferSize is the number of undelivered events a configuration watch
+// may hold before it is disconnected
+const watchBufferSize = 64
+
+// Configuration event types
+const (
+	ConfigUpdated = "updated"
+	ConfigDeleted = "deleted"
+	ConfigEvicted = "evicted" // An auto-created client was evicted
+)
+
+// ConfigEvent describes a change to a client's configuration
+type ConfigEvent struct {
+	Type     string              `json:"type"`
+	ClientID string              `json:"client_id"`
+	Config   *types.ClientConfig `json:"config,omitempty"` // Unset for deletions and evictions
+	Time     time.Time           `json:"time"`
+}
+
+// ConfigWatch receives the configuration changes of one client or all clients
+type ConfigWatch struct {
+	// Events receives changes in the order they were applied. It is closed
+	// when the watch is closed or falls more than watchBufferSize events behind.
+	Events <-chan ConfigEvent
+
+	events   chan ConfigEvent
+	clientID string
+	manager  *Manager
+}
+
+// StatsDelta is the change in a client's statistics over one interval of a stats watch
+type StatsDelta struct {
+	ClientID        string             `json:"client_id"`
+	TotalRequests   int64              `json:"total_requests"`
+	SuccessRequests int64              `json:"success_requests"`
+	DroppedRequests int64              `json:"dropped_requests"`
+	RPMDropped      int64              `json:"rpm_dropped"`
+	TPMDropped      int64              `json:"tpm_dropped"`
+	UpstreamDropped int64              `json:"upstream_dropped"`
+	TokensUsed      int64              `json:"tokens_used"`
+	ShadowDropped   int64              `json:"shadow_dropped,omitempty"`
+	Stats           *types.ClientStats `json:"stats"` // Statistics at the end of the interval
+}
+
+// WatchConfigs starts watching the configuration of a client, or of all
+// clients if clientID is empty. It also returns the current configurations,
+// sorted by client ID, so no change is missed between the two.
+func (m *Manager) WatchConfigs(clientID string) ([]*types.ClientConfig, *ConfigWatch) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var configs []*types.ClientConfig
+	for id, client := range m.clients {
+		if clientID == "" || id == clientID {
+			configs = append(configs, client.config)
+		}
+	}
+	sort.Slice(configs, func(i, j int) bool {
+		return configs[i].ClientID < configs[j].ClientID
+	})
+
+	events := make(chan ConfigEvent, watchBufferSize)
+	watch := &ConfigWatch{
+		Events:   events,
+		events:   events,
+		clientID: clientID,
+		manager:  m,
+	}
+	m.watchers[watch] = struct{}{}
+	return configs, watch
+}
+
+// Close stops the watch and closes its Events channel
+func (w *ConfigWatch) Close() {
+	w.manager.mutex.Lock()
+	defer w.manager.mutex.Unlock()
+
+	w.manager.dropWatcher(w)
+}
+
+// publishConfig sends a change to the watches of its client, disconnecting
+// any that are too far behind so they cannot stall configuration changes.
+// Must be called with the mutex held.
+func (m *Manager) publishConfig(event ConfigEvent) {
+	for watch := range m.watchers {
+		if watch.clientID != "" && watch.clientID != event.ClientID {
+			continue
+		}
+		select {
+		case watch.events <- event:
+		default:
+			m.dropWatcher(watch)
+		}
+	}
+}
+
+// dropWatcher removes a watch and closes its channel. Must be called with the mutex held.
+func (m *Manager) dropWatcher(watch *ConfigWatch) {
+	if _, exists := m.watchers[watch]; exists {
+		delete(m.watchers, watch)
+		close(watch.events)
+	}
+}
+
+// WatchStats sends the change in the statistics of a client, or of all
+// clients if clientID is empty, every interval until ctx is done. Clients
+// without new requests are left out, so an update may be empty; the first
+// update covers everything since each client was added.
+func (m *Manager) WatchStats(ctx context.Context, clientID string, interval time.Duration) <-chan []StatsDelta {
+	updates := make(chan []StatsDelta)
+
+	go func() {
+		defer close(updates)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		previous := make(map[string]*types.ClientStats)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			var current map[string]*types.ClientStats
+			if clientID == "" {
+				current = m.GetAllStats()
+			} else {
+				current = make(map[string]*types.ClientStats)
+				if stats, exists := m.GetClientStats(clientID); exists {
+					current[clientID] = stats
+				}
+			}
+
+			deltas := []StatsDelta{}
+			for id, stats := range current {
+				delta := statsDelta(previous[id], stats)
+				if delta.TotalRequests != 0 {
+					deltas = append(deltas, delta)
+				}
+			}
+			sort.Slice(deltas, func(i, j int) bool {
+				return deltas[i].ClientID < deltas[j].ClientID
+			})
+
+			select {
+			case updates <- deltas:
+				// Deltas are relative to the last update delivered
+				previous = current
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return updates
+}
+
+// statsDelta returns the change from before to after. Counters that went
+// backwards belong to a client that was deleted and re-created, so they are
+// counted from zero.
+func statsDelta(before, after *types.ClientStats) StatsDelta {
+	if before == nil || after.TotalRequests < before.TotalRequests {
+		before = &types.ClientStats{}
+	}
+
+	return StatsDelta{
+		ClientID:        after.ClientID,
+		TotalRequests:   after.TotalRequests - before.TotalRequests,
+		SuccessRequests: after.SuccessRequests - before.SuccessRequests,
+		DroppedRequests: after.DroppedRequests - before.DroppedRequests,
+		RPMDropped:      after.RPMDropped - before.RPMDropped,
+		TPMDropped:      after.TPMDropped - before.TPMDropped,
+		UpstreamDropped: after.UpstreamDropped - before.UpstreamDropped,
+		TokensUsed:      after.TokensUsed - before.TokensUsed,
+		ShadowDropped:   after.ShadowDropped - before.ShadowDropped,
+		Stats:           after,
+	}
+}
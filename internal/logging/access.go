@@ -0,0 +1,183 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"time"
+)
+
+// Access log field names
+const (
+	FieldClientID      = "client_id"
+	FieldMethod        = "method"
+	FieldPath          = "path"
+	FieldStatus        = "status"
+	FieldLatency       = "latency_ms"
+	FieldTTFB          = "ttfb_ms"
+	FieldTokenEstimate = "token_estimate"
+	FieldActualTokens  = "actual_tokens"
+	FieldDecision      = "decision"
+	FieldUpstream      = "upstream"
+	FieldModel         = "model"
+	FieldTraceID       = "trace_id"
+)
+
+// AllFields lists every access log field in output order
+var AllFields = []string{
+	FieldClientID, FieldMethod, FieldPath, FieldStatus, FieldLatency, FieldTTFB,
+	FieldTokenEstimate, FieldActualTokens, FieldDecision, FieldUpstream, FieldModel, FieldTraceID,
+}
+
+// AccessEntry describes one proxied request
+type AccessEntry struct {
+	ClientID      string
+	Method        string
+	Path          string
+	Status        int
+	Latency       time.Duration
+	TTFB          time.Duration
+	TokenEstimate int64
+	ActualTokens  int64  // -1 when the upstream did not report usage
+	Decision      string // allowed, the drop reason, shadow_ and the reason it would have been dropped, or invalid_request
+	Upstream      string // Upstream target that served the request
+	Model         string
+	TraceID       string
+}
+
+// AccessLogConfig selects the fields and sampling of access logs
+type AccessLogConfig struct {
+	Fields      []string           // Fields to include (empty includes all)
+	SampleRate  float64            // Fraction of requests logged
+	ClientRates map[string]float64 // Per-client sample rates, overriding SampleRate
+}
+
+// AccessLogger writes one structured record per proxied request.
+// Server errors (5xx) are always logged regardless of sampling.
+type AccessLogger struct {
+	logger      *slog.Logger
+	fields      []string
+	sampleRate  float64
+	clientRates map[string]float64
+}
+
+// NewAccessLogger creates an access logger writing to logger
+func NewAccessLogger(logger *slog.Logger, config AccessLogConfig) (*AccessLogger, error) {
+	known := make(map[string]bool, len(AllFields))
+	for _, field := range AllFields {
+		known[field] = true
+	}
+
+	fields := AllFields
+	if len(config.Fields) > 0 {
+		fields = config.Fields
+		for _, field := range fields {
+			if !known[field] {
+				return nil, fmt.Errorf("unknown access log field: %s", field)
+			}
+		}
+	}
+
+	rates := []float64{config.SampleRate}
+	for _, rate := range config.ClientRates {
+		rates = append(rates, rate)
+	}
+	for _, rate := range rates {
+		if rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("sample rate %v must be between 0 and 1", rate)
+		}
+	}
+
+	return &AccessLogger{
+		logger:      logger,
+		fields:      fields,
+		sampleRate:  config.SampleRate,
+		clientRates: config.ClientRates,
+	}, nil
+}
+
+// Log writes the access record for a request, subject to sampling
+func (a *AccessLogger) Log(ctx context.Context, entry AccessEntry) {
+	level := slog.LevelInfo
+	if entry.Status >= 500 {
+		level = slog.LevelWarn
+	} else if !a.sampled(entry.ClientID) {
+		return
+	}
+
+	if !a.logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := make([]slog.Attr, 0, len(a.fields))
+	for _, field := range a.fields {
+		if attr, ok := entry.attr(field); ok {
+			attrs = append(attrs, attr)
+		}
+	}
+
+	a.logger.LogAttrs(ctx, level, "access", attrs...)
+}
+
+// Includes reports whether a field is written to the access log
+func (a *AccessLogger) Includes(field string) bool {
+	for _, f := range a.fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// sampled decides whether a request from a client is logged
+func (a *AccessLogger) sampled(clientID string) bool {
+	rate, exists := a.clientRates[clientID]
+	if !exists {
+		rate = a.sampleRate
+	}
+
+	switch {
+	case rate >= 1:
+		return true
+	case rate <= 0:
+		return false
+	}
+	return rand.Float64() < rate
+}
+
+// attr returns the log attribute for a field, omitting empty optional values
+func (e AccessEntry) attr(field string) (slog.Attr, bool) {
+	switch field {
+	case FieldClientID:
+		return slog.String(field, e.ClientID), true
+	case FieldMethod:
+		return slog.String(field, e.Method), true
+	case FieldPath:
+		return slog.String(field, e.Path), true
+	case FieldStatus:
+		return slog.Int(field, e.Status), true
+	case FieldLatency:
+		return slog.Float64(field, durationMs(e.Latency)), true
+	case FieldTTFB:
+		return slog.Float64(field, durationMs(e.TTFB)), e.TTFB > 0
+	case FieldTokenEstimate:
+		return slog.Int64(field, e.TokenEstimate), true
+	case FieldActualTokens:
+		return slog.Int64(field, e.ActualTokens), e.ActualTokens >= 0
+	case FieldDecision:
+		return slog.String(field, e.Decision), true
+	case FieldUpstream:
+		return slog.String(field, e.Upstream), e.Upstream != ""
+	case FieldModel:
+		return slog.String(field, e.Model), e.Model != ""
+	case FieldTraceID:
+		return slog.String(field, e.TraceID), e.TraceID != ""
+	}
+	return slog.Attr{}, false
+}
+
+// durationMs converts a duration to fractional milliseconds
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,64 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Config holds log output settings
+type Config struct {
+	Output     string // stdout, stderr or a file path
+	Level      string // debug, info, warn or error
+	MaxSizeMB  int    // Rotate file output once it reaches this size (0 disables rotation)
+	MaxBackups int    // Number of rotated files kept
+}
+
+// New creates a JSON logger writing to the configured output.
+// The returned closer releases the output file, if any.
+func New(config Config) (*slog.Logger, io.Closer, error) {
+	level, err := ParseLevel(config.Level)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var output io.WriteCloser
+	switch config.Output {
+	case "", "stdout":
+		output = nopCloser{os.Stdout}
+	case "stderr":
+		output = nopCloser{os.Stderr}
+	default:
+		output, err = NewRotatingFile(config.Output, int64(config.MaxSizeMB)<<20, config.MaxBackups)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	handler := slog.NewJSONHandler(output, &slog.HandlerOptions{Level: level})
+	return slog.New(handler), output, nil
+}
+
+// ParseLevel converts a level name to a slog level
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return slog.LevelInfo, fmt.Errorf("unknown log level: %s", name)
+}
+
+// nopCloser keeps the standard streams open when the logger is closed
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,121 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// rotateRetryInterval is how long a failed rotation waits before it is tried again
+const rotateRetryInterval = time.Minute
+
+// RotatingFile is a log file that is rotated once it reaches a maximum size.
+// Rotated files are renamed to path.1, path.2, ... with path.1 the newest.
+type RotatingFile struct {
+	path       string
+	maxBytes   int64
+	maxBackups int
+	file       *os.File
+	size       int64
+	retryAt    time.Time // Rotation is not attempted before this time after a failure
+	mutex      sync.Mutex
+}
+
+// NewRotatingFile opens a log file for appending. A maxBytes of 0 disables rotation.
+func NewRotatingFile(path string, maxBytes int64, maxBackups int) (*RotatingFile, error) {
+	rf := &RotatingFile{
+		path:       path,
+		maxBytes:   maxBytes,
+		maxBackups: maxBackups,
+	}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+// Write implements io.Writer, rotating the file first if the write would exceed the maximum size.
+// If rotation fails the write goes to the current file, and rotation is retried after rotateRetryInterval.
+func (rf *RotatingFile) Write(p []byte) (int, error) {
+	rf.mutex.Lock()
+	defer rf.mutex.Unlock()
+
+	if rf.maxBytes > 0 && rf.size > 0 && rf.size+int64(len(p)) > rf.maxBytes && !time.Now().Before(rf.retryAt) {
+		if err := rf.rotate(); err != nil {
+			// The log itself cannot report the failure
+			fmt.Fprintf(os.Stderr, "flowguard: %v\n", err)
+			rf.retryAt = time.Now().Add(rotateRetryInterval)
+		}
+	}
+
+	n, err := rf.file.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+// Close closes the current file
+func (rf *RotatingFile) Close() error {
+	rf.mutex.Lock()
+	defer rf.mutex.Unlock()
+	return rf.file.Close()
+}
+
+// open opens the log file and records its current size. Must be called with the mutex held.
+func (rf *RotatingFile) open() error {
+	file, err := os.OpenFile(rf.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+
+	rf.file = file
+	rf.size = info.Size()
+	return nil
+}
+
+// rotate moves the current file aside, reopens it, then shifts the backups
+// and makes the moved file path.1. Backups are only shifted once a new file
+// is open, and the current file stays open until then, so a failed rotation
+// loses neither backups nor log lines. Must be called with the mutex held.
+func (rf *RotatingFile) rotate() error {
+	if rf.maxBackups == 0 {
+		if err := rf.file.Truncate(0); err != nil {
+			return fmt.Errorf("failed to truncate log file: %w", err)
+		}
+		rf.size = 0
+		return nil
+	}
+
+	rotating := rf.path + ".rotating"
+	if err := os.Rename(rf.path, rotating); err != nil {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+
+	previous := rf.file
+	if err := rf.open(); err != nil {
+		// Put the current file back so the next attempt starts over
+		os.Rename(rotating, rf.path)
+		return err
+	}
+	previous.Close()
+
+	os.Remove(backupName(rf.path, rf.maxBackups))
+	for i := rf.maxBackups - 1; i >= 1; i-- {
+		os.Rename(backupName(rf.path, i), backupName(rf.path, i+1))
+	}
+	if err := os.Rename(rotating, backupName(rf.path, 1)); err != nil {
+		return fmt.Errorf("failed to rename rotated log file: %w", err)
+	}
+	return nil
+}
+
+// backupName returns the name of the n-th rotated file
+func backupName(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"flowguard/internal/limiter"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// adaptiveCollector exports the adaptive limiter's state at scrape time
+type adaptiveCollector struct {
+	adaptive          *limiter.AdaptiveLimiter
+	admissionRPM      *prometheus.Desc
+	remainingRequests *prometheus.Desc
+	remainingTokens   *prometheus.Desc
+	throttled         *prometheus.Desc
+	decreases         *prometheus.Desc
+}
+
+// RegisterAdaptiveLimiter registers metrics for the adaptive upstream limiter
+func (m *Metrics) RegisterAdaptiveLimiter(adaptive *limiter.AdaptiveLimiter) {
+	prometheus.MustRegister(&adaptiveCollector{
+		adaptive: adaptive,
+		admissionRPM: prometheus.NewDesc(
+			"flowguard_adaptive_admission_rpm",
+			"Current global admission rate chosen by the adaptive limiter",
+			nil, nil,
+		),
+		remainingRequests: prometheus.NewDesc(
+			"flowguard_upstream_ratelimit_remaining_requests",
+			"Modelled requests remaining at the upstream provider (-1 if unknown)",
+			nil, nil,
+		),
+		remainingTokens: prometheus.NewDesc(
+			"flowguard_upstream_ratelimit_remaining_tokens",
+			"Modelled tokens remaining at the upstream provider (-1 if unknown)",
+			nil, nil,
+		),
+		throttled: prometheus.NewDesc(
+			"flowguard_adaptive_throttled_total",
+			"Total number of requests rejected by the adaptive limiter",
+			nil, nil,
+		),
+		decreases: prometheus.NewDesc(
+			"flowguard_adaptive_decreases_total",
+			"Total number of multiplicative decreases of the admission rate",
+			nil, nil,
+		),
+	})
+}
+
+// Describe implements prometheus.Collector
+func (c *adaptiveCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.admissionRPM
+	ch <- c.remainingRequests
+	ch <- c.remainingTokens
+	ch <- c.throttled
+	ch <- c.decreases
+}
+
+// Collect implements prometheus.Collector
+func (c *adaptiveCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.adaptive.Stats()
+	ch <- prometheus.MustNewConstMetric(c.admissionRPM, prometheus.GaugeValue, float64(stats.AdmissionRPM))
+	ch <- prometheus.MustNewConstMetric(c.remainingRequests, prometheus.GaugeValue, float64(stats.RemainingRequests))
+	ch <- prometheus.MustNewConstMetric(c.remainingTokens, prometheus.GaugeValue, float64(stats.RemainingTokens))
+	ch <- prometheus.MustNewConstMetric(c.throttled, prometheus.CounterValue, float64(stats.Throttled))
+	ch <- prometheus.MustNewConstMetric(c.decreases, prometheus.CounterValue, float64(stats.Decreases))
+}
@@ -0,0 +1,27 @@
+package metrics
+
+import (
+	"flowguard/internal/capture"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RegisterCaptureRecorder registers metrics for the audit capture recorder
+func (m *Metrics) RegisterCaptureRecorder(recorder *capture.Recorder) {
+	prometheus.MustRegister(
+		prometheus.NewCounterFunc(
+			prometheus.CounterOpts{
+				Name: "flowguard_capture_records_total",
+				Help: "Total number of request/response records written by audit capture",
+			},
+			func() float64 { return float64(recorder.Recorded()) },
+		),
+		prometheus.NewCounterFunc(
+			prometheus.CounterOpts{
+				Name: "flowguard_capture_dropped_total",
+				Help: "Total number of audit capture records dropped because the writer fell behind",
+			},
+			func() float64 { return float64(recorder.Dropped()) },
+		),
+	)
+}
@@ -14,20 +14,29 @@ import (
 type Metrics struct {
 	requestsTotal     *prometheus.CounterVec
 	requestsDropped   *prometheus.CounterVec
+	shadowDropped     *prometheus.CounterVec
 	tokensUsed        *prometheus.CounterVec
 	tokensRemaining   *prometheus.GaugeVec
 	requestDuration   *prometheus.HistogramVec
+	timeToFirstByte   *prometheus.HistogramVec
+	upstreamDuration  *prometheus.HistogramVec
 	bucketsRemaining  *prometheus.GaugeVec
+	clientsEvicted    *prometheus.CounterVec
+	autoClients       prometheus.Gauge
+	upstreams         *upstreamCollector
 	rateLimiter       *limiter.Manager
 }
 
+// latencyBuckets covers fast cached responses up to long streamed generations
+var latencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 20, 30, 60, 120, 180, 300}
+
 // NewMetrics creates and registers Prometheus metrics
 func NewMetrics(rateLimiter *limiter.Manager) *Metrics {
 	m := &Metrics{
 		requestsTotal: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "flowguard_requests_total",
-				Help: "Total number of requests processed by FlowGuard",
+				Help: "Total number of requests processed by FlowGuard, by status (success, error, dropped)",
 			},
 			[]string{"client_id", "status"},
 		),
@@ -38,6 +47,13 @@ func NewMetrics(rateLimiter *limiter.Manager) *Metrics {
 			},
 			[]string{"client_id", "reason"},
 		),
+		shadowDropped: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "flowguard_requests_shadow_dropped_total",
+				Help: "Requests forwarded for clients in shadow mode that enforcing their limits would have dropped",
+			},
+			[]string{"client_id", "reason"},
+		),
 		tokensUsed: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "flowguard_tokens_used_total",
@@ -54,9 +70,25 @@ func NewMetrics(rateLimiter *limiter.Manager) *Metrics {
 		),
 		requestDuration: prometheus.NewHistogramVec(
 			prometheus.HistogramOpts{
-				Name:    "flowguard_request_duration_milliseconds",
-				Help:    "Request latency in milliseconds",
-				Buckets: prometheus.ExponentialBuckets(1, 2, 10), // 1ms to ~1s
+				Name:    "flowguard_request_duration_seconds",
+				Help:    "End-to-end request latency in seconds",
+				Buckets: latencyBuckets, // 5ms to 5m
+			},
+			[]string{"client_id"},
+		),
+		timeToFirstByte: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "flowguard_time_to_first_byte_seconds",
+				Help:    "Time until the first response byte was sent to the client, in seconds",
+				Buckets: latencyBuckets,
+			},
+			[]string{"client_id"},
+		),
+		upstreamDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "flowguard_upstream_duration_seconds",
+				Help:    "Time spent on the upstream call, excluding rate limiting, in seconds",
+				Buckets: latencyBuckets,
 			},
 			[]string{"client_id"},
 		),
@@ -67,6 +99,19 @@ func NewMetrics(rateLimiter *limiter.Manager) *Metrics {
 			},
 			[]string{"client_id", "limit_type"},
 		),
+		clientsEvicted: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "flowguard_clients_evicted_total",
+				Help: "Clients created on first use that were evicted, by reason (capacity, idle, rule)",
+			},
+			[]string{"reason"},
+		),
+		autoClients: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "flowguard_auto_clients",
+				Help: "Current number of clients created automatically for unknown client IDs",
+			},
+		),
 		rateLimiter: rateLimiter,
 	}
 
@@ -74,67 +119,92 @@ func NewMetrics(rateLimiter *limiter.Manager) *Metrics {
 	prometheus.MustRegister(
 		m.requestsTotal,
 		m.requestsDropped,
+		m.shadowDropped,
 		m.tokensUsed,
 		m.tokensRemaining,
 		m.requestDuration,
+		m.timeToFirstByte,
+		m.upstreamDuration,
 		m.bucketsRemaining,
+		m.clientsEvicted,
+		m.autoClients,
 	)
 
 	return m
 }
 
-// UpdateMetrics updates Prometheus metrics with current data from rate limiter
+// UpdateMetrics refreshes the bucket gauges with current data from the rate limiter.
+// Counters and histograms are updated per request through the observer methods.
 func (m *Metrics) UpdateMetrics() {
 	stats := m.rateLimiter.GetAllStats()
-	configs := m.rateLimiter.GetAllClients()
+	m.autoClients.Set(float64(m.rateLimiter.AutoClientCount()))
 
 	for clientID, stat := range stats {
-		// Update request metrics
-		m.requestsTotal.WithLabelValues(clientID, "success").Add(float64(stat.SuccessRequests))
-		m.requestsTotal.WithLabelValues(clientID, "total").Add(float64(stat.TotalRequests))
-
-		// Update dropped request metrics
-		m.requestsDropped.WithLabelValues(clientID, "rpm").Add(float64(stat.RPMDropped))
-		m.requestsDropped.WithLabelValues(clientID, "tpm").Add(float64(stat.TPMDropped))
-
-		// Update token metrics
-		m.tokensUsed.WithLabelValues(clientID).Add(float64(stat.TokensUsed))
-
 		// Update remaining token gauges
 		m.tokensRemaining.WithLabelValues(clientID, "rpm").Set(float64(stat.RPMRemaining))
 		m.tokensRemaining.WithLabelValues(clientID, "tpm").Set(float64(stat.TPMRemaining))
 
-		// Update latency histogram
-		if stat.AvgLatencyMs > 0 {
-			m.requestDuration.WithLabelValues(clientID).Observe(stat.AvgLatencyMs)
-		}
-
 		// Update rate limit remaining gauges
-		if config, exists := configs[clientID]; exists {
-			if config.RPM != nil {
+		if limits, exists := m.rateLimiter.GetEffectiveLimits(clientID); exists {
+			if limits.RPM != nil {
 				m.bucketsRemaining.WithLabelValues(clientID, "rpm").Set(float64(stat.RPMRemaining))
 			}
-			if config.TPM != nil {
+			if limits.TPM != nil {
 				m.bucketsRemaining.WithLabelValues(clientID, "tpm").Set(float64(stat.TPMRemaining))
 			}
 		}
 	}
 }
 
-// RecordRequest records a successful request
-func (m *Metrics) RecordRequest(clientID string, duration time.Duration) {
-	m.requestsTotal.WithLabelValues(clientID, "success").Inc()
-	m.requestDuration.WithLabelValues(clientID).Observe(float64(duration.Milliseconds()))
+// RequestAllowed implements limiter.Observer and records token consumption
+func (m *Metrics) RequestAllowed(clientID string, tokens int64) {
+	m.tokensUsed.WithLabelValues(clientID).Add(float64(tokens))
 }
 
-// RecordDroppedRequest records a dropped request
-func (m *Metrics) RecordDroppedRequest(clientID, reason string) {
+// RequestDropped implements limiter.Observer and records a dropped request
+func (m *Metrics) RequestDropped(clientID, reason string) {
+	m.requestsTotal.WithLabelValues(clientID, "dropped").Inc()
 	m.requestsDropped.WithLabelValues(clientID, reason).Inc()
 }
 
-// RecordTokensUsed records token consumption
-func (m *Metrics) RecordTokensUsed(clientID string, tokens int64) {
-	m.tokensUsed.WithLabelValues(clientID).Add(float64(tokens))
+// RequestShadowDropped implements limiter.Observer and records a request
+// that was forwarded in shadow mode although it was over the limit
+func (m *Metrics) RequestShadowDropped(clientID, reason string) {
+	m.shadowDropped.WithLabelValues(clientID, reason).Inc()
+}
+
+// ClientEvicted implements limiter.Observer, counting the eviction and
+// deleting the client's series so evicted clients do not accumulate
+func (m *Metrics) ClientEvicted(clientID, reason string) {
+	m.clientsEvicted.WithLabelValues(reason).Inc()
+	m.ClientRemoved(clientID)
+}
+
+// ClientRemoved implements limiter.Observer and deletes the series of a deleted client
+func (m *Metrics) ClientRemoved(clientID string) {
+	labels := prometheus.Labels{"client_id": clientID}
+	m.requestsTotal.DeletePartialMatch(labels)
+	m.requestsDropped.DeletePartialMatch(labels)
+	m.shadowDropped.DeletePartialMatch(labels)
+	m.tokensUsed.DeletePartialMatch(labels)
+	m.tokensRemaining.DeletePartialMatch(labels)
+	m.requestDuration.DeletePartialMatch(labels)
+	m.timeToFirstByte.DeletePartialMatch(labels)
+	m.upstreamDuration.DeletePartialMatch(labels)
+	m.bucketsRemaining.DeletePartialMatch(labels)
+}
+
+// RequestCompleted implements proxy.Observer and records a forwarded request
+func (m *Metrics) RequestCompleted(clientID string, statusCode int, latency limiter.LatencySample) {
+	status := "success"
+	if statusCode >= 500 {
+		status = "error"
+	}
+
+	m.requestsTotal.WithLabelValues(clientID, status).Inc()
+	m.requestDuration.WithLabelValues(clientID).Observe(latency.Total.Seconds())
+	m.timeToFirstByte.WithLabelValues(clientID).Observe(latency.TTFB.Seconds())
+	m.upstreamDuration.WithLabelValues(clientID).Observe(latency.Upstream.Seconds())
 }
 
 // StartMetricsUpdater starts a goroutine that periodically updates metrics
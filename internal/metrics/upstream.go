@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"sync"
+
+	"flowguard/internal/proxy"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// upstreamCollector exports per-target metrics for upstream pools at scrape time
+type upstreamCollector struct {
+	pools       []*proxy.Pool
+	mutex       sync.Mutex
+	requests    *prometheus.Desc
+	failures    *prometheus.Desc
+	outstanding *prometheus.Desc
+	healthy     *prometheus.Desc
+	retries     *prometheus.Desc
+	circuit     *prometheus.Desc
+}
+
+// RegisterUpstreamPool registers per-target metrics for an upstream pool
+func (m *Metrics) RegisterUpstreamPool(pool *proxy.Pool) {
+	if m.upstreams == nil {
+		m.upstreams = newUpstreamCollector()
+		prometheus.MustRegister(m.upstreams)
+	}
+
+	m.upstreams.mutex.Lock()
+	defer m.upstreams.mutex.Unlock()
+	m.upstreams.pools = append(m.upstreams.pools, pool)
+}
+
+// newUpstreamCollector creates an empty upstream collector
+func newUpstreamCollector() *upstreamCollector {
+	labels := []string{"upstream", "target"}
+	return &upstreamCollector{
+		requests: prometheus.NewDesc(
+			"flowguard_upstream_requests_total",
+			"Total number of requests sent to each upstream target",
+			labels, nil,
+		),
+		failures: prometheus.NewDesc(
+			"flowguard_upstream_failures_total",
+			"Total number of connection errors and 5xx responses from each upstream target",
+			labels, nil,
+		),
+		outstanding: prometheus.NewDesc(
+			"flowguard_upstream_outstanding_requests",
+			"Current number of in-flight requests to each upstream target",
+			labels, nil,
+		),
+		healthy: prometheus.NewDesc(
+			"flowguard_upstream_healthy",
+			"Whether each upstream target is receiving traffic (1) or is unhealthy or ejected (0)",
+			labels, nil,
+		),
+		retries: prometheus.NewDesc(
+			"flowguard_upstream_retries_total",
+			"Total number of retried attempts to each upstream",
+			[]string{"upstream"}, nil,
+		),
+		circuit: prometheus.NewDesc(
+			"flowguard_upstream_circuit_state",
+			"Circuit breaker state of each upstream (0 closed, 1 half-open, 2 open)",
+			[]string{"upstream"}, nil,
+		),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *upstreamCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.requests
+	ch <- c.failures
+	ch <- c.outstanding
+	ch <- c.healthy
+	ch <- c.retries
+	ch <- c.circuit
+}
+
+// Collect implements prometheus.Collector
+func (c *upstreamCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mutex.Lock()
+	pools := c.pools
+	c.mutex.Unlock()
+
+	for _, pool := range pools {
+		c.collectPool(ch, pool)
+	}
+}
+
+// collectPool exports the metrics of a single pool
+func (c *upstreamCollector) collectPool(ch chan<- prometheus.Metric, pool *proxy.Pool) {
+	status := pool.Status()
+	name := status.Name
+
+	ch <- prometheus.MustNewConstMetric(c.retries, prometheus.CounterValue, float64(status.Retries), name)
+	ch <- prometheus.MustNewConstMetric(c.circuit, prometheus.GaugeValue, float64(pool.Breaker().State()), name)
+
+	for _, target := range status.Targets {
+		healthy := 0.0
+		if target.Healthy && !target.Ejected {
+			healthy = 1
+		}
+
+		ch <- prometheus.MustNewConstMetric(c.requests, prometheus.CounterValue, float64(target.Requests), name, target.URL)
+		ch <- prometheus.MustNewConstMetric(c.failures, prometheus.CounterValue, float64(target.Failures), name, target.URL)
+		ch <- prometheus.MustNewConstMetric(c.outstanding, prometheus.GaugeValue, float64(target.Outstanding), name, target.URL)
+		ch <- prometheus.MustNewConstMetric(c.healthy, prometheus.GaugeValue, healthy, name, target.URL)
+	}
+}
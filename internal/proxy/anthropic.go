@@ -0,0 +1,420 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// anthropicVersion is the Messages API version requested from Anthropic
+const anthropicVersion = "2023-06-01"
+
+// defaultAnthropicMaxTokens is used when an OpenAI request does not set max_tokens,
+// since the Messages API requires it
+const defaultAnthropicMaxTokens = 4096
+
+// anthropicAdapter translates OpenAI chat completions to and from the Anthropic Messages API
+type anthropicAdapter struct {
+	apiKey string
+}
+
+type anthropicUsage struct {
+	InputTokens  int64 `json:"input_tokens"`
+	OutputTokens int64 `json:"output_tokens"`
+}
+
+type anthropicMessage struct {
+	ID      string `json:"id"`
+	Model   string `json:"model"`
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	StopReason string         `json:"stop_reason"`
+	Usage      anthropicUsage `json:"usage"`
+}
+
+type anthropicError struct {
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// NewRequest implements Adapter
+func (a *anthropicAdapter) NewRequest(ctx context.Context, original *http.Request, body map[string]interface{}, model string) (*http.Request, error) {
+	payload, err := toAnthropicRequest(body, model)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "/v1/messages", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Anthropic-Version", anthropicVersion)
+	if a.apiKey != "" {
+		req.Header.Set("X-Api-Key", a.apiKey)
+	} else if key := original.Header.Get("X-Api-Key"); key != "" {
+		req.Header.Set("X-Api-Key", key)
+	}
+	if stream, _ := payload["stream"].(bool); stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+
+	return req, nil
+}
+
+// toAnthropicRequest converts an OpenAI chat completion body to a Messages API body
+func toAnthropicRequest(body map[string]interface{}, model string) (map[string]interface{}, error) {
+	if _, hasTools := body["tools"]; hasTools {
+		return nil, fmt.Errorf("%w: tool calls", errUnsupportedRequest)
+	}
+	if n, ok := body["n"].(float64); ok && n > 1 {
+		return nil, fmt.Errorf("%w: n > 1", errUnsupportedRequest)
+	}
+
+	rawMessages, ok := body["messages"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: messages must be an array", errUnsupportedRequest)
+	}
+
+	var system []string
+	messages := make([]interface{}, 0, len(rawMessages))
+	for _, raw := range rawMessages {
+		message, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%w: invalid message", errUnsupportedRequest)
+		}
+
+		role, _ := message["role"].(string)
+		switch role {
+		case "system", "developer":
+			text, err := contentText(message["content"])
+			if err != nil {
+				return nil, err
+			}
+			system = append(system, text)
+		case "user", "assistant":
+			content, err := toAnthropicContent(message["content"])
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, map[string]interface{}{
+				"role":    role,
+				"content": content,
+			})
+		default:
+			return nil, fmt.Errorf("%w: %s messages", errUnsupportedRequest, role)
+		}
+	}
+
+	payload := map[string]interface{}{
+		"model":      model,
+		"messages":   messages,
+		"max_tokens": defaultAnthropicMaxTokens,
+	}
+
+	if maxTokens, ok := body["max_completion_tokens"].(float64); ok {
+		payload["max_tokens"] = int64(maxTokens)
+	} else if maxTokens, ok := body["max_tokens"].(float64); ok {
+		payload["max_tokens"] = int64(maxTokens)
+	}
+	if len(system) > 0 {
+		payload["system"] = strings.Join(system, "\n\n")
+	}
+	for _, key := range []string{"temperature", "top_p", "stream"} {
+		if value, ok := body[key]; ok {
+			payload[key] = value
+		}
+	}
+	switch stop := body["stop"].(type) {
+	case string:
+		payload["stop_sequences"] = []string{stop}
+	case []interface{}:
+		payload["stop_sequences"] = stop
+	}
+	if user, ok := body["user"].(string); ok && user != "" {
+		payload["metadata"] = map[string]interface{}{"user_id": user}
+	}
+
+	return payload, nil
+}
+
+// contentText flattens OpenAI message content into plain text
+func contentText(content interface{}) (string, error) {
+	switch c := content.(type) {
+	case string:
+		return c, nil
+	case []interface{}:
+		var parts []string
+		for _, raw := range c {
+			part, _ := raw.(map[string]interface{})
+			if partType, _ := part["type"].(string); partType != "text" {
+				return "", fmt.Errorf("%w: non-text system content", errUnsupportedRequest)
+			}
+			text, _ := part["text"].(string)
+			parts = append(parts, text)
+		}
+		return strings.Join(parts, "\n"), nil
+	case nil:
+		return "", nil
+	default:
+		return "", fmt.Errorf("%w: invalid content", errUnsupportedRequest)
+	}
+}
+
+// toAnthropicContent converts OpenAI message content into Anthropic content blocks
+func toAnthropicContent(content interface{}) (interface{}, error) {
+	parts, ok := content.([]interface{})
+	if !ok {
+		return contentText(content)
+	}
+
+	blocks := make([]interface{}, 0, len(parts))
+	for _, raw := range parts {
+		part, _ := raw.(map[string]interface{})
+		switch partType, _ := part["type"].(string); partType {
+		case "text":
+			blocks = append(blocks, map[string]interface{}{"type": "text", "text": part["text"]})
+		case "image_url":
+			imageURL, _ := part["image_url"].(map[string]interface{})
+			url, _ := imageURL["url"].(string)
+			blocks = append(blocks, map[string]interface{}{"type": "image", "source": toAnthropicImageSource(url)})
+		default:
+			return nil, fmt.Errorf("%w: %s content", errUnsupportedRequest, partType)
+		}
+	}
+	return blocks, nil
+}
+
+// toAnthropicImageSource converts an image URL or data URL into an image source
+func toAnthropicImageSource(url string) map[string]interface{} {
+	if strings.HasPrefix(url, "data:") {
+		if header, data, found := strings.Cut(strings.TrimPrefix(url, "data:"), ","); found {
+			return map[string]interface{}{
+				"type":       "base64",
+				"media_type": strings.TrimSuffix(header, ";base64"),
+				"data":       data,
+			}
+		}
+	}
+	return map[string]interface{}{"type": "url", "url": url}
+}
+
+// WriteResponse implements Adapter
+func (a *anthropicAdapter) WriteResponse(w http.ResponseWriter, resp *http.Response, stream bool, includeUsage bool) error {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	if resp.StatusCode != http.StatusOK {
+		return writeAnthropicError(w, resp)
+	}
+	if stream {
+		return writeAnthropicStream(w, resp, includeUsage)
+	}
+
+	var message anthropicMessage
+	if err := json.NewDecoder(resp.Body).Decode(&message); err != nil {
+		writeError(w, http.StatusBadGateway, "invalid_upstream_response", "Invalid response from upstream provider")
+		return err
+	}
+
+	var text strings.Builder
+	for _, block := range message.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":      "chatcmpl-" + message.ID,
+		"object":  "chat.completion",
+		"created": time.Now().Unix(),
+		"model":   message.Model,
+		"choices": []interface{}{
+			map[string]interface{}{
+				"index": 0,
+				"message": map[string]interface{}{
+					"role":    "assistant",
+					"content": text.String(),
+				},
+				"finish_reason": toOpenAIFinishReason(message.StopReason),
+			},
+		},
+		"usage": toOpenAIUsage(message.Usage),
+	})
+}
+
+// writeAnthropicError translates an Anthropic error response into the OpenAI error format
+func writeAnthropicError(w http.ResponseWriter, resp *http.Response) error {
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	var upstreamErr anthropicError
+	if json.Unmarshal(data, &upstreamErr) != nil || upstreamErr.Error.Message == "" {
+		upstreamErr.Error.Type = "upstream_error"
+		upstreamErr.Error.Message = strings.TrimSpace(string(data))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(resp.StatusCode)
+	return json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": upstreamErr.Error.Message,
+			"type":    upstreamErr.Error.Type,
+			"code":    nil,
+		},
+	})
+}
+
+// writeAnthropicStream translates a Messages API event stream into OpenAI chat completion chunks
+func writeAnthropicStream(w http.ResponseWriter, resp *http.Response, includeUsage bool) error {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	controller := http.NewResponseController(w)
+	created := time.Now().Unix()
+	var id, model string
+	var usage anthropicUsage
+
+	writeChunk := func(chunk interface{}) error {
+		data, err := json.Marshal(chunk)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+			return err
+		}
+		controller.Flush()
+		return nil
+	}
+
+	newChunk := func(delta map[string]interface{}, finishReason interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"id":      id,
+			"object":  "chat.completion.chunk",
+			"created": created,
+			"model":   model,
+			"choices": []interface{}{
+				map[string]interface{}{"index": 0, "delta": delta, "finish_reason": finishReason},
+			},
+		}
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "data:") {
+			data.WriteString(strings.TrimSpace(strings.TrimPrefix(line, "data:")))
+			continue
+		}
+		if line != "" || data.Len() == 0 {
+			continue
+		}
+
+		var event struct {
+			Type    string           `json:"type"`
+			Message anthropicMessage `json:"message"`
+			Delta   struct {
+				Type       string `json:"type"`
+				Text       string `json:"text"`
+				StopReason string `json:"stop_reason"`
+			} `json:"delta"`
+			Usage anthropicUsage `json:"usage"`
+			Error struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		payload := data.String()
+		data.Reset()
+		if err := json.Unmarshal([]byte(payload), &event); err != nil {
+			continue
+		}
+
+		var err error
+		switch event.Type {
+		case "message_start":
+			id = "chatcmpl-" + event.Message.ID
+			model = event.Message.Model
+			usage.InputTokens = event.Message.Usage.InputTokens
+			err = writeChunk(newChunk(map[string]interface{}{"role": "assistant", "content": ""}, nil))
+		case "content_block_delta":
+			if event.Delta.Type == "text_delta" {
+				err = writeChunk(newChunk(map[string]interface{}{"content": event.Delta.Text}, nil))
+			}
+		case "message_delta":
+			usage.OutputTokens = event.Usage.OutputTokens
+			if event.Delta.StopReason != "" {
+				err = writeChunk(newChunk(map[string]interface{}{}, toOpenAIFinishReason(event.Delta.StopReason)))
+			}
+		case "message_stop":
+			if includeUsage {
+				chunk := newChunk(nil, nil)
+				chunk["choices"] = []interface{}{}
+				chunk["usage"] = toOpenAIUsage(usage)
+				if err := writeChunk(chunk); err != nil {
+					return err
+				}
+			}
+			if _, err := io.WriteString(w, "data: [DONE]\n\n"); err != nil {
+				return err
+			}
+			controller.Flush()
+			return nil
+		case "error":
+			return writeChunk(map[string]interface{}{
+				"error": map[string]interface{}{"message": event.Error.Message, "type": event.Error.Type},
+			})
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+// toOpenAIFinishReason maps an Anthropic stop reason to an OpenAI finish reason
+func toOpenAIFinishReason(stopReason string) string {
+	switch stopReason {
+	case "max_tokens":
+		return "length"
+	case "tool_use":
+		return "tool_calls"
+	case "refusal":
+		return "content_filter"
+	default:
+		return "stop"
+	}
+}
+
+// toOpenAIUsage converts Anthropic token usage to the OpenAI usage object
+func toOpenAIUsage(usage anthropicUsage) map[string]interface{} {
+	return map[string]interface{}{
+		"prompt_tokens":     usage.InputTokens,
+		"completion_tokens": usage.OutputTokens,
+		"total_tokens":      usage.InputTokens + usage.OutputTokens,
+	}
+}
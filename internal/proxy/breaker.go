@@ -0,0 +1,179 @@
+package proxy
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// CircuitState is the state of a circuit breaker
+type CircuitState int
+
+// Circuit breaker states
+const (
+	CircuitClosed CircuitState = iota
+	CircuitHalfOpen
+	CircuitOpen
+)
+
+// String returns the state name
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitHalfOpen:
+		return "half_open"
+	case CircuitOpen:
+		return "open"
+	default:
+		return "closed"
+	}
+}
+
+// ErrCircuitOpen is returned when an upstream's circuit breaker is rejecting requests
+var ErrCircuitOpen = errors.New("upstream circuit breaker is open")
+
+// BreakerConfig holds the configuration for a circuit breaker
+type BreakerConfig struct {
+	FailureThreshold int           // Consecutive failures that open the circuit (0 disables the breaker)
+	Cooldown         time.Duration // How long the circuit stays open before probing
+	HalfOpenProbes   int           // Concurrent probe requests allowed while half-open
+}
+
+// CircuitBreaker fails fast when an upstream keeps failing.
+// It opens after FailureThreshold consecutive failures, rejects requests for
+// Cooldown, then lets a limited number of probes through; a successful probe
+// closes the circuit and a failed one reopens it.
+type CircuitBreaker struct {
+	name     string
+	config   BreakerConfig
+	mutex    sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probes   int
+}
+
+// BreakerStats is a point-in-time snapshot of a circuit breaker
+type BreakerStats struct {
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+}
+
+// NewCircuitBreaker creates a new circuit breaker
+func NewCircuitBreaker(name string, config BreakerConfig) *CircuitBreaker {
+	if config.Cooldown <= 0 {
+		config.Cooldown = 30 * time.Second
+	}
+	if config.HalfOpenProbes <= 0 {
+		config.HalfOpenProbes = 1
+	}
+	return &CircuitBreaker{name: name, config: config}
+}
+
+// Allow reports whether a request may be sent. Every allowed request must be
+// followed by a call to Record or Release.
+func (cb *CircuitBreaker) Allow() error {
+	if cb.config.FailureThreshold <= 0 {
+		return nil
+	}
+
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	switch cb.state {
+	case CircuitOpen:
+		if time.Since(cb.openedAt) < cb.config.Cooldown {
+			return ErrCircuitOpen
+		}
+		cb.setState(CircuitHalfOpen)
+		cb.probes = 1
+		return nil
+	case CircuitHalfOpen:
+		if cb.probes >= cb.config.HalfOpenProbes {
+			return ErrCircuitOpen
+		}
+		cb.probes++
+		return nil
+	default:
+		return nil
+	}
+}
+
+// Record records the outcome of an allowed request
+func (cb *CircuitBreaker) Record(success bool) {
+	if cb.config.FailureThreshold <= 0 {
+		return
+	}
+
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	if cb.state == CircuitHalfOpen && cb.probes > 0 {
+		cb.probes--
+	}
+
+	if success {
+		cb.failures = 0
+		if cb.state != CircuitClosed {
+			cb.setState(CircuitClosed)
+		}
+		return
+	}
+
+	cb.failures++
+	if cb.state == CircuitHalfOpen || cb.failures >= cb.config.FailureThreshold {
+		cb.openedAt = time.Now()
+		cb.setState(CircuitOpen)
+	}
+}
+
+// Release gives back an allowed request whose outcome says nothing about the
+// upstream, such as one cancelled by the client, without recording a result
+func (cb *CircuitBreaker) Release() {
+	if cb.config.FailureThreshold <= 0 {
+		return
+	}
+
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	if cb.state == CircuitHalfOpen && cb.probes > 0 {
+		cb.probes--
+	}
+}
+
+// State returns the current breaker state
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	return cb.state
+}
+
+// Stats returns a snapshot of the breaker
+func (cb *CircuitBreaker) Stats() BreakerStats {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	stats := BreakerStats{
+		State:               cb.state.String(),
+		ConsecutiveFailures: cb.failures,
+	}
+	if cb.state != CircuitClosed {
+		openedAt := cb.openedAt
+		stats.OpenedAt = &openedAt
+	}
+	return stats
+}
+
+// setState transitions the breaker, logging the change. Must be called with the mutex held.
+func (cb *CircuitBreaker) setState(state CircuitState) {
+	if cb.state == state {
+		return
+	}
+	log.Printf("Circuit breaker for upstream %s: %s -> %s", cb.name, cb.state, state)
+	cb.state = state
+	if state == CircuitClosed {
+		cb.probes = 0
+	}
+}
@@ -1,37 +1,78 @@
 package proxy
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"net/http/httputil"
-	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
+	"flowguard/internal/capture"
 	"flowguard/internal/limiter"
+	"flowguard/internal/logging"
+	"flowguard/internal/tracing"
 	"flowguard/internal/types"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// Observer is notified when a forwarded request completes
+type Observer interface {
+	RequestCompleted(clientID string, statusCode int, latency limiter.LatencySample)
+}
+
 // Handler handles HTTP requests with rate limiting and proxying
 type Handler struct {
 	rateLimiter *limiter.Manager
 	upstream    *httputil.ReverseProxy
-	upstreamURL *url.URL
+	pool        *Pool
+	router      *Router
+	rewriter    *Rewriter
+	observer    Observer
+	accessLog   *logging.AccessLogger
+	recorder    *capture.Recorder
 }
 
-// NewHandler creates a new proxy handler
-func NewHandler(upstreamURL string, rateLimiter *limiter.Manager) (*Handler, error) {
-	parsedURL, err := url.Parse(upstreamURL)
-	if err != nil {
-		return nil, fmt.Errorf("invalid upstream URL: %w", err)
+// NewHandler creates a new proxy handler that forwards to the given upstream pool
+func NewHandler(pool *Pool, rateLimiter *limiter.Manager) (*Handler, error) {
+	if pool == nil {
+		return nil, fmt.Errorf("upstream pool is required")
+	}
+
+	proxy := &httputil.ReverseProxy{
+		// The target host is chosen per request by the pool transport
+		Director: func(req *http.Request) {
+			if _, ok := req.Header["User-Agent"]; !ok {
+				// Explicitly disable the default User-Agent
+				req.Header.Set("User-Agent", "")
+			}
+		},
+		Transport: &upstreamTransport{pool: pool, base: http.DefaultTransport},
 	}
 
-	proxy := httputil.NewSingleHostReverseProxy(parsedURL)
-	
 	// Customize the proxy to preserve headers and handle errors
 	proxy.ModifyResponse = func(resp *http.Response) error {
+		// Feed the provider's rate limit state to the adaptive limiter
+		rateLimiter.ObserveUpstream(parseUpstreamSignal(resp))
+
+		// Record which upstream served the request
+		rateLimiter.RecordProvider(rateLimiter.ResolveClientID(resp.Request.Header.Get("X-Client-ID")), pool.Name())
+		resp.Header.Set(ProviderHeader, pool.Name())
+
+		// Read the actual token usage as the response is streamed
+		trackUsage(resp, requestInfoFrom(resp.Request.Context()))
+
 		// Add CORS headers if needed
 		resp.Header.Set("Access-Control-Allow-Origin", "*")
 		return nil
@@ -39,67 +80,348 @@ func NewHandler(upstreamURL string, rateLimiter *limiter.Manager) (*Handler, err
 
 	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
 		log.Printf("Proxy error: %v", err)
+		switch {
+		case errors.Is(err, ErrNoHealthyTargets):
+			writeError(w, http.StatusServiceUnavailable, "no_healthy_upstream", "No healthy upstream targets available")
+			return
+		case errors.Is(err, ErrCircuitOpen):
+			writeError(w, http.StatusServiceUnavailable, "circuit_open", "Upstream is unavailable, circuit breaker is open")
+			return
+		}
 		http.Error(w, "Bad Gateway", http.StatusBadGateway)
 	}
 
 	return &Handler{
 		rateLimiter: rateLimiter,
 		upstream:    proxy,
-		upstreamURL: parsedURL,
+		pool:        pool,
 	}, nil
 }
 
+// SetRouter enables provider failover for routed chat completion models
+func (h *Handler) SetRouter(router *Router) {
+	h.router = router
+}
+
+// SetObserver registers an observer for completed requests
+func (h *Handler) SetObserver(observer Observer) {
+	h.observer = observer
+}
+
+// SetAccessLogger enables structured access logging
+func (h *Handler) SetAccessLogger(accessLog *logging.AccessLogger) {
+	h.accessLog = accessLog
+}
+
+// SetRecorder enables audit capture of request and response bodies
+func (h *Handler) SetRecorder(recorder *capture.Recorder) {
+	h.recorder = recorder
+}
+
+// SetRewriter enables model aliasing and request rewriting rules
+func (h *Handler) SetRewriter(rewriter *Rewriter) {
+	h.rewriter = rewriter
+}
+
 // ServeHTTP handles incoming HTTP requests
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	startTime := time.Now()
 
+	// Continue the caller's trace, if any
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := tracing.Tracer().Start(ctx, "flowguard.request",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.request.method", r.Method),
+			attribute.String("url.path", r.URL.Path),
+		),
+	)
+	defer span.End()
+	r, info := withRequestInfo(r.WithContext(ctx))
+
+	// Create a custom response writer to capture status code and first byte time
+	wrappedWriter := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK, ctx: ctx}
+	defer wrappedWriter.endResponseSpan()
+
+	// Every request gets one access log record, including rejected ones
+	entry := logging.AccessEntry{
+		Method:       r.Method,
+		Path:         r.URL.Path,
+		ActualTokens: -1,
+		Decision:     "invalid_request",
+	}
+	if span.SpanContext().HasTraceID() {
+		entry.TraceID = span.SpanContext().TraceID().String()
+	}
+	defer func() {
+		if h.accessLog == nil {
+			return
+		}
+		entry.Status = wrappedWriter.statusCode
+		entry.Latency = time.Since(startTime)
+		entry.Upstream = info.Target()
+		entry.ActualTokens = info.ActualTokens()
+		h.accessLog.Log(ctx, entry)
+	}()
+
 	// Extract required headers
 	clientID := r.Header.Get("X-Client-ID")
 	tokenEstimateStr := r.Header.Get("X-Token-Estimate")
+	span.SetAttributes(tracing.AttrClientID.String(clientID))
+	entry.ClientID = clientID
 
 	// Validate headers
 	if clientID == "" {
-		h.writeErrorResponse(w, http.StatusBadRequest, "missing_header", "X-Client-ID header is required")
+		h.writeErrorResponse(wrappedWriter, http.StatusBadRequest, "missing_header", "X-Client-ID header is required")
 		return
 	}
 
 	if tokenEstimateStr == "" {
-		h.writeErrorResponse(w, http.StatusBadRequest, "missing_header", "X-Token-Estimate header is required")
+		h.writeErrorResponse(wrappedWriter, http.StatusBadRequest, "missing_header", "X-Token-Estimate header is required")
 		return
 	}
 
 	tokenEstimate, err := strconv.ParseInt(tokenEstimateStr, 10, 64)
 	if err != nil || tokenEstimate < 0 {
-		h.writeErrorResponse(w, http.StatusBadRequest, "invalid_header", "X-Token-Estimate must be a non-negative integer")
+		h.writeErrorResponse(wrappedWriter, http.StatusBadRequest, "invalid_header", "X-Token-Estimate must be a non-negative integer")
 		return
 	}
+	span.SetAttributes(tracing.AttrTokenEstimate.Int64(tokenEstimate))
+	entry.TokenEstimate = tokenEstimate
+
+	// Apply model aliases and rewriting rules before rate limiting
+	body := h.readJSONBody(r)
+	if body != nil && h.rewriter != nil && h.rewriter.Apply(clientID, body) {
+		if err := replaceJSONBody(r, body); err != nil {
+			h.writeErrorResponse(wrappedWriter, http.StatusInternalServerError, "internal_error", "Failed to rewrite request body")
+			return
+		}
+	}
+	if model, ok := body["model"].(string); ok {
+		span.SetAttributes(tracing.AttrModel.String(model))
+		entry.Model = model
+	}
 
 	// Check rate limits
-	if err := h.rateLimiter.CheckAndConsume(clientID, tokenEstimate); err != nil {
+	quota := h.checkRateLimit(ctx, clientID, tokenEstimate)
+	if err := quota.Err; err != nil {
+		if errors.Is(err, types.ErrUnknownClient) {
+			entry.Decision = types.ErrUnknownClient.Type
+			h.writeErrorResponse(wrappedWriter, http.StatusForbidden, types.ErrUnknownClient.Type, "X-Client-ID is not a configured client")
+			return
+		}
 		if rateLimitErr, ok := err.(types.RateLimitError); ok {
-			h.writeErrorResponse(w, http.StatusTooManyRequests, rateLimitErr.Type, rateLimitErr.Message)
+			entry.Decision = rateLimitErr.Type
+			h.writeErrorResponse(wrappedWriter, http.StatusTooManyRequests, rateLimitErr.Type, rateLimitErr.Message)
 			return
 		}
-		h.writeErrorResponse(w, http.StatusInternalServerError, "internal_error", "Internal server error")
+		if _, ok := err.(*types.ValidationError); ok {
+			entry.Decision = "invalid_request"
+			h.writeErrorResponse(wrappedWriter, http.StatusBadRequest, "invalid_header", "X-Client-ID is not a valid client ID")
+			return
+		}
+		entry.Decision = "internal_error"
+		h.writeErrorResponse(wrappedWriter, http.StatusInternalServerError, "internal_error", "Internal server error")
 		return
 	}
+	entry.Decision = "allowed"
+	if shadow, ok := quota.Shadow.(types.RateLimitError); ok {
+		// Forwarded in shadow mode, but enforcing the limits would have dropped it
+		entry.Decision = "shadow_" + shadow.Type
+	}
 
-	// Create a custom response writer to capture status code
-	wrappedWriter := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+	// Statistics belong to the client whose limits applied, which is the
+	// shared default client for unknown IDs under the default policy
+	statsClientID := h.rateLimiter.ResolveClientID(clientID)
 
-	// Forward the request to upstream
-	h.upstream.ServeHTTP(wrappedWriter, r)
+	// Capture the bodies of audited clients; the record is written asynchronously
+	capturing := h.recorder != nil && h.recorder.Enabled(clientID)
+	var requestBody []byte
+	requestComplete := true
+	if capturing {
+		requestBody, requestComplete = readRawBody(r, h.recorder.MaxBodyBytes())
+		wrappedWriter.capture = capture.NewBuffer(h.recorder.MaxBodyBytes())
+	}
+
+	upstreamStart := time.Now()
+
+	// Send routed chat completions through the provider failover chain,
+	// and everything else to the default upstream. Only default upstream
+	// responses feed the adaptive limiter, which models that provider's
+	// capacity; routed providers have their own limits and fail over on 429s.
+	if targets, routed := h.route(r, body); routed {
+		provider, err := h.router.Serve(wrappedWriter, r, body, targets)
+		if provider != "" {
+			h.rateLimiter.RecordProvider(statsClientID, provider)
+		}
+		if err != nil {
+			log.Printf("Routed request from client %s failed: %v", clientID, err)
+			if provider == "" {
+				h.writeErrorResponse(wrappedWriter, http.StatusBadGateway, "all_providers_failed", "All upstream providers failed")
+			}
+		}
+	} else {
+		h.upstream.ServeHTTP(wrappedWriter, r)
+	}
+	wrappedWriter.endResponseSpan()
 
 	// Update latency metrics
-	latency := time.Since(startTime)
-	h.rateLimiter.UpdateLatency(clientID, float64(latency.Milliseconds()))
+	now := time.Now()
+	latency := limiter.LatencySample{
+		Total:    now.Sub(startTime),
+		TTFB:     now.Sub(startTime),
+		Upstream: now.Sub(upstreamStart),
+	}
+	if !wrappedWriter.firstByte.IsZero() {
+		latency.TTFB = wrappedWriter.firstByte.Sub(startTime)
+	}
+	entry.TTFB = latency.TTFB
+	h.rateLimiter.RecordLatency(statsClientID, latency)
+
+	if capturing {
+		truncated := wrappedWriter.capture.Truncated() || !requestComplete
+		h.recorder.Record(capture.Record{
+			Time:      startTime,
+			ClientID:  clientID,
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			Status:    wrappedWriter.statusCode,
+			Model:     entry.Model,
+			TraceID:   entry.TraceID,
+			Request:   requestBody,
+			Response:  wrappedWriter.capture.Bytes(),
+			Stream:    strings.HasPrefix(wrappedWriter.Header().Get("Content-Type"), "text/event-stream"),
+			Truncated: truncated,
+		})
+	}
+	if h.observer != nil {
+		h.observer.RequestCompleted(statsClientID, wrappedWriter.statusCode, latency)
+	}
+
+	span.SetAttributes(
+		attribute.Int("http.response.status_code", wrappedWriter.statusCode),
+		tracing.AttrUpstream.String(info.Target()),
+	)
+	if actual := info.ActualTokens(); actual >= 0 {
+		span.SetAttributes(tracing.AttrActualTokens.Int64(actual))
+	}
+	if wrappedWriter.statusCode >= 500 {
+		span.SetStatus(codes.Error, http.StatusText(wrappedWriter.statusCode))
+	}
+}
+
+// checkRateLimit runs the limiter decision in its own span. Requests are
+// admitted or rejected immediately rather than queued, so there is no
+// queue wait to trace.
+func (h *Handler) checkRateLimit(ctx context.Context, clientID string, tokenEstimate int64) limiter.Quota {
+	_, span := tracing.Tracer().Start(ctx, "flowguard.rate_limit")
+	defer span.End()
+
+	quota := h.rateLimiter.ConsumeQuota(clientID, 1, tokenEstimate)
+	if err := quota.Err; err != nil {
+		span.SetAttributes(tracing.AttrDecision.String("dropped"))
+		if rateLimitErr, ok := err.(types.RateLimitError); ok {
+			span.SetAttributes(attribute.String("flowguard.limiter.reason", rateLimitErr.Type))
+		}
+		return quota
+	}
 
-	log.Printf("Request from client %s: %s %s - %d (%v)", 
-		clientID, r.Method, r.URL.Path, wrappedWriter.statusCode, latency)
+	span.SetAttributes(tracing.AttrDecision.String("allowed"))
+	if shadow, ok := quota.Shadow.(types.RateLimitError); ok {
+		span.SetAttributes(attribute.String("flowguard.limiter.shadow_reason", shadow.Type))
+	}
+	return quota
+}
+
+// readJSONBody parses the JSON body of a POST request when rewriting, routing,
+// a sampled trace or the access log needs it. The raw body is restored so it
+// can still be proxied.
+func (h *Handler) readJSONBody(r *http.Request) map[string]interface{} {
+	needed := h.router != nil || h.rewriter != nil ||
+		trace.SpanFromContext(r.Context()).IsRecording() ||
+		(h.accessLog != nil && h.accessLog.Includes(logging.FieldModel))
+	if !needed {
+		return nil
+	}
+	if r.Method != http.MethodPost || r.Body == nil || strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		return nil
+	}
+
+	// Bodies over the cap are proxied untouched rather than held in memory
+	data, complete := readRawBody(r, maxJSONBodyBytes)
+	if !complete {
+		return nil
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil
+	}
+	return body
+}
+
+// maxJSONBodyBytes is the largest request body parsed for rewriting, routing and logging
+const maxJSONBodyBytes = 4 << 20
+
+// readRawBody reads up to maxBytes of a request body and restores it so it
+// can still be proxied. complete is false, and data holds only the first
+// bytes read, if the body is longer than maxBytes or cannot be read.
+func readRawBody(r *http.Request, maxBytes int) (data []byte, complete bool) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, true
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, int64(maxBytes)+1))
+	if err != nil {
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(data))
+		return data, false
+	}
+
+	if len(data) > maxBytes {
+		// Stream the rest of the body after the bytes already read
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(data), r.Body), r.Body}
+		return data[:maxBytes], false
+	}
+
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	return data, true
+}
+
+// replaceJSONBody replaces a request body with the encoding of body
+func replaceJSONBody(r *http.Request, body map[string]interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	r.ContentLength = int64(len(data))
+	r.Header.Set("Content-Length", strconv.Itoa(len(data)))
+	return nil
+}
+
+// route returns the fallback list for a chat completion whose model is routed
+func (h *Handler) route(r *http.Request, body map[string]interface{}) ([]RouteTarget, bool) {
+	if h.router == nil || body == nil || !isChatCompletion(r) {
+		return nil, false
+	}
+
+	model, _ := body["model"].(string)
+	return h.router.Route(model)
 }
 
 // writeErrorResponse writes a JSON error response
 func (h *Handler) writeErrorResponse(w http.ResponseWriter, statusCode int, errorType, message string) {
+	writeError(w, statusCode, errorType, message)
+}
+
+// writeError writes a JSON error response
+func writeError(w http.ResponseWriter, statusCode int, errorType, message string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
 	
@@ -111,13 +433,53 @@ func (h *Handler) writeErrorResponse(w http.ResponseWriter, statusCode int, erro
 	json.NewEncoder(w).Encode(errorResp)
 }
 
-// responseWriter wraps http.ResponseWriter to capture status code
+// responseWriter wraps http.ResponseWriter to capture status code and
+// the time the first byte of the response was sent. Writing the response
+// is traced as a span from the first byte until the request completes.
 type responseWriter struct {
 	http.ResponseWriter
-	statusCode int
+	statusCode   int
+	firstByte    time.Time
+	ctx          context.Context
+	responseSpan trace.Span
+	capture      *capture.Buffer // Copy of the response body for audit capture
 }
 
 func (rw *responseWriter) WriteHeader(code int) {
 	rw.statusCode = code
+	rw.markFirstByte()
 	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *responseWriter) Write(data []byte) (int, error) {
+	rw.markFirstByte()
+	n, err := rw.ResponseWriter.Write(data)
+	if rw.capture != nil {
+		rw.capture.Write(data[:n])
+	}
+	return n, err
+}
+
+// markFirstByte records the time of the first write
+func (rw *responseWriter) markFirstByte() {
+	if rw.firstByte.IsZero() {
+		rw.firstByte = time.Now()
+		if rw.ctx != nil {
+			_, rw.responseSpan = tracing.Tracer().Start(rw.ctx, "flowguard.response")
+		}
+	}
+}
+
+// endResponseSpan ends the response span once the response is complete
+func (rw *responseWriter) endResponseSpan() {
+	if rw.responseSpan != nil {
+		rw.responseSpan.End()
+		rw.responseSpan = nil
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, so streamed
+// responses can be flushed
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
 } 
\ No newline at end of file
@@ -0,0 +1,254 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"flowguard/internal/limiter"
+	"flowguard/internal/tracing"
+	"flowguard/internal/types"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	testTraceID    = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testParentSpan = "00f067aa0ba902b7"
+)
+
+// setupTracing installs a tracer provider that exports spans synchronously
+// to an in-memory exporter, restoring the global provider after the test
+func setupTracing(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	previousProvider := otel.GetTracerProvider()
+	previousPropagator := otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		provider.Shutdown(context.Background())
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+	return exporter
+}
+
+// newTestHandler creates a handler forwarding to upstream, with one client
+// limited to rpm requests per minute
+func newTestHandler(t *testing.T, upstream *httptest.Server, rpm int64) *Handler {
+	t.Helper()
+
+	pool, err := NewPool(PoolConfig{
+		Name:    "test-upstream",
+		Targets: []TargetConfig{{URL: upstream.URL, Weight: 1}},
+	})
+	if err != nil {
+		t.Fatalf("NewPool: %v", err)
+	}
+
+	rateLimiter := limiter.NewManager()
+	if err := rateLimiter.SetClientConfig(&types.ClientConfig{ClientID: "trace-client", RPM: &rpm, Enabled: true}); err != nil {
+		t.Fatalf("SetClientConfig: %v", err)
+	}
+
+	handler, err := NewHandler(pool, rateLimiter)
+	if err != nil {
+		t.Fatalf("NewHandler: %v", err)
+	}
+	return handler
+}
+
+// newChatRequest builds a chat completion request from trace-client that continues the test trace
+func newChatRequest() *http.Request {
+	body := `{"model":"gpt-test","messages":[{"role":"user","content":"hi"}]}`
+	r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("X-Client-ID", "trace-client")
+	r.Header.Set("X-Token-Estimate", "10")
+	r.Header.Set("traceparent", "00-"+testTraceID+"-"+testParentSpan+"-01")
+	return r
+}
+
+// spansByName indexes ended spans by name, failing if a name is repeated
+func spansByName(t *testing.T, spans tracetest.SpanStubs) map[string]tracetest.SpanStub {
+	t.Helper()
+
+	byName := make(map[string]tracetest.SpanStub)
+	for _, span := range spans {
+		if _, exists := byName[span.Name]; exists {
+			t.Fatalf("span %s recorded more than once", span.Name)
+		}
+		byName[span.Name] = span
+	}
+	return byName
+}
+
+// attributes returns the attributes of a span as a map
+func attributes(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	values := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes {
+		values[kv.Key] = kv.Value
+	}
+	return values
+}
+
+func TestServeHTTPTracesForwardedRequest(t *testing.T) {
+	exporter := setupTracing(t)
+
+	var upstreamTraceparent string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamTraceparent = r.Header.Get("traceparent")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"chatcmpl-1","usage":{"prompt_tokens":12,"completion_tokens":30,"total_tokens":42}}`))
+	}))
+	defer upstream.Close()
+
+	handler := newTestHandler(t, upstream, 60)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, newChatRequest())
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	spans := spansByName(t, exporter.GetSpans())
+	request, ok := spans["flowguard.request"]
+	if !ok {
+		t.Fatalf("no flowguard.request span among %d spans", len(spans))
+	}
+
+	// The incoming traceparent is continued
+	if got := request.SpanContext.TraceID().String(); got != testTraceID {
+		t.Errorf("request trace ID = %s, want %s", got, testTraceID)
+	}
+	if got := request.Parent.SpanID().String(); got != testParentSpan || !request.Parent.IsRemote() {
+		t.Errorf("request parent = %s (remote %t), want remote %s", got, request.Parent.IsRemote(), testParentSpan)
+	}
+	if request.SpanKind != trace.SpanKindServer {
+		t.Errorf("request span kind = %s, want server", request.SpanKind)
+	}
+
+	// The limiter, upstream and response spans are children of the request span
+	for _, name := range []string{"flowguard.rate_limit", "flowguard.upstream", "flowguard.response"} {
+		span, ok := spans[name]
+		if !ok {
+			t.Errorf("no %s span", name)
+			continue
+		}
+		if span.Parent.SpanID() != request.SpanContext.SpanID() {
+			t.Errorf("%s parent = %s, want request span %s", name, span.Parent.SpanID(), request.SpanContext.SpanID())
+		}
+	}
+
+	requestAttrs := attributes(request)
+	wantRequestAttrs := map[attribute.Key]attribute.Value{
+		tracing.AttrClientID:      attribute.StringValue("trace-client"),
+		tracing.AttrModel:         attribute.StringValue("gpt-test"),
+		tracing.AttrTokenEstimate: attribute.Int64Value(10),
+		tracing.AttrActualTokens:  attribute.Int64Value(42),
+		tracing.AttrUpstream:      attribute.StringValue(upstream.URL),
+	}
+	for key, want := range wantRequestAttrs {
+		if got, ok := requestAttrs[key]; !ok || got != want {
+			t.Errorf("request attribute %s = %v, want %v", key, got.Emit(), want.Emit())
+		}
+	}
+
+	if got := attributes(spans["flowguard.rate_limit"])[tracing.AttrDecision].AsString(); got != "allowed" {
+		t.Errorf("rate limit decision = %q, want allowed", got)
+	}
+
+	upstreamSpan := spans["flowguard.upstream"]
+	if upstreamSpan.SpanKind != trace.SpanKindClient {
+		t.Errorf("upstream span kind = %s, want client", upstreamSpan.SpanKind)
+	}
+	upstreamAttrs := attributes(upstreamSpan)
+	if got := upstreamAttrs[tracing.AttrProvider].AsString(); got != "test-upstream" {
+		t.Errorf("upstream provider = %q, want test-upstream", got)
+	}
+	if got := upstreamAttrs[tracing.AttrAttempt].AsInt64(); got != 1 {
+		t.Errorf("upstream attempt = %d, want 1", got)
+	}
+
+	// The upstream sees the trace with the upstream attempt span as its parent
+	wantTraceparent := "00-" + testTraceID + "-" + upstreamSpan.SpanContext.SpanID().String() + "-01"
+	if upstreamTraceparent != wantTraceparent {
+		t.Errorf("upstream traceparent = %q, want %q", upstreamTraceparent, wantTraceparent)
+	}
+}
+
+func TestServeHTTPTracesDroppedRequest(t *testing.T) {
+	exporter := setupTracing(t)
+
+	upstreamCalls := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamCalls++
+	}))
+	defer upstream.Close()
+
+	handler := newTestHandler(t, upstream, 1)
+	handler.ServeHTTP(httptest.NewRecorder(), newChatRequest())
+	exporter.Reset()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, newChatRequest())
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if upstreamCalls != 1 {
+		t.Errorf("upstream calls = %d, want 1", upstreamCalls)
+	}
+
+	spans := spansByName(t, exporter.GetSpans())
+	if _, ok := spans["flowguard.upstream"]; ok {
+		t.Error("dropped request has an upstream span")
+	}
+
+	rateLimit, ok := spans["flowguard.rate_limit"]
+	if !ok {
+		t.Fatal("no flowguard.rate_limit span")
+	}
+	attrs := attributes(rateLimit)
+	if got := attrs[tracing.AttrDecision].AsString(); got != "dropped" {
+		t.Errorf("rate limit decision = %q, want dropped", got)
+	}
+	if got := attrs["flowguard.limiter.reason"].AsString(); got != types.ErrRPMExceeded.Type {
+		t.Errorf("rate limit reason = %q, want %s", got, types.ErrRPMExceeded.Type)
+	}
+	if rateLimit.SpanContext.TraceID().String() != testTraceID {
+		t.Errorf("rate limit trace ID = %s, want %s", rateLimit.SpanContext.TraceID(), testTraceID)
+	}
+}
+
+func TestReadRawBodyCapsLargeBodies(t *testing.T) {
+	body := strings.Repeat("x", 100)
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	data, complete := readRawBody(r, 10)
+	if complete || string(data) != body[:10] {
+		t.Errorf("readRawBody = %q, %t; want the first 10 bytes, incomplete", data, complete)
+	}
+	if rest, _ := io.ReadAll(r.Body); string(rest) != body {
+		t.Errorf("restored body has %d bytes, want %d", len(rest), len(body))
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	data, complete = readRawBody(r, len(body))
+	if !complete || string(data) != body {
+		t.Errorf("readRawBody = %d bytes, %t; want the whole body", len(data), complete)
+	}
+	if rest, _ := io.ReadAll(r.Body); string(rest) != body {
+		t.Errorf("restored body has %d bytes, want %d", len(rest), len(body))
+	}
+}
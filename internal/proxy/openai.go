@@ -0,0 +1,47 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// openAIAdapter forwards OpenAI chat completions unchanged apart from the model
+type openAIAdapter struct {
+	apiKey string
+}
+
+// NewRequest implements Adapter
+func (a *openAIAdapter) NewRequest(ctx context.Context, original *http.Request, body map[string]interface{}, model string) (*http.Request, error) {
+	payload := make(map[string]interface{}, len(body))
+	for key, value := range body {
+		payload[key] = value
+	}
+	payload["model"] = model
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "/v1/chat/completions", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header = original.Header.Clone()
+	req.Header.Del("Content-Length")
+	req.Header.Del("Accept-Encoding")
+	req.Header.Set("Content-Type", "application/json")
+	if a.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+a.apiKey)
+	}
+
+	return req, nil
+}
+
+// WriteResponse implements Adapter
+func (a *openAIAdapter) WriteResponse(w http.ResponseWriter, resp *http.Response, stream bool, includeUsage bool) error {
+	return copyResponse(w, resp)
+}
@@ -0,0 +1,398 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Strategy selects how requests are distributed across the targets of a pool
+type Strategy string
+
+// Load balancing strategies
+const (
+	StrategyRoundRobin       Strategy = "round_robin"
+	StrategyLeastOutstanding Strategy = "least_outstanding"
+	StrategyWeighted         Strategy = "weighted"
+)
+
+// ErrNoHealthyTargets is returned when every target in a pool is unhealthy or ejected
+var ErrNoHealthyTargets = errors.New("no healthy upstream targets")
+
+// TargetConfig describes a single backend in an upstream pool
+type TargetConfig struct {
+	URL    string
+	Weight int // Relative weight for the weighted strategy (defaults to 1)
+}
+
+// PoolConfig holds the configuration for an upstream pool
+type PoolConfig struct {
+	Name                string
+	Targets             []TargetConfig
+	Strategy            Strategy
+	HealthCheckPath     string        // Path probed by active health checks (empty disables them)
+	HealthCheckInterval time.Duration // Time between active health checks
+	HealthCheckTimeout  time.Duration // Timeout for a single health check request
+	EjectAfter          int           // Consecutive failures before passive ejection (0 disables)
+	EjectDuration       time.Duration // How long an ejected target is skipped
+	Retry               RetryPolicy   // Retries for failed requests
+	Breaker             BreakerConfig // Circuit breaker for the whole pool
+}
+
+// Target is a single backend in an upstream pool
+type Target struct {
+	URL    *url.URL
+	weight int
+
+	outstanding int64 // accessed atomically
+	requests    int64 // accessed atomically
+	failures    int64 // accessed atomically
+
+	mutex               sync.Mutex
+	healthy             bool      // result of the last active health check
+	consecutiveFailures int       // consecutive passive failures
+	ejectedUntil        time.Time // passive ejection deadline
+	currentWeight       int       // smooth weighted round-robin state
+}
+
+// TargetStats is a point-in-time snapshot of a target's state
+type TargetStats struct {
+	URL         string `json:"url"`
+	Weight      int    `json:"weight"`
+	Healthy     bool   `json:"healthy"`
+	Ejected     bool   `json:"ejected"`
+	Outstanding int64  `json:"outstanding"`
+	Requests    int64  `json:"requests"`
+	Failures    int64  `json:"failures"`
+}
+
+// UpstreamStatus is a point-in-time snapshot of an upstream pool
+type UpstreamStatus struct {
+	Name     string        `json:"name"`
+	Strategy Strategy      `json:"strategy"`
+	Circuit  BreakerStats  `json:"circuit"`
+	Retries  int64         `json:"retries"`
+	Targets  []TargetStats `json:"targets"`
+}
+
+// available reports whether the target can receive traffic
+func (t *Target) available(now time.Time) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.healthy && !now.Before(t.ejectedUntil)
+}
+
+// Pool distributes requests across a set of equivalent upstream targets
+type Pool struct {
+	name     string
+	targets  []*Target
+	strategy Strategy
+	config   PoolConfig
+	next     uint64 // round-robin cursor, accessed atomically
+	retries  int64  // retried attempts, accessed atomically
+	breaker  *CircuitBreaker
+	mutex    sync.Mutex
+	client   *http.Client
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewPool creates a new upstream pool
+func NewPool(config PoolConfig) (*Pool, error) {
+	if len(config.Targets) == 0 {
+		return nil, errors.New("upstream pool requires at least one target")
+	}
+
+	switch config.Strategy {
+	case "":
+		config.Strategy = StrategyRoundRobin
+	case StrategyRoundRobin, StrategyLeastOutstanding, StrategyWeighted:
+	default:
+		return nil, fmt.Errorf("unknown load balancing strategy: %s", config.Strategy)
+	}
+
+	if config.Name == "" {
+		config.Name = "default"
+	}
+	if config.HealthCheckInterval <= 0 {
+		config.HealthCheckInterval = 10 * time.Second
+	}
+	if config.HealthCheckTimeout <= 0 {
+		config.HealthCheckTimeout = 2 * time.Second
+	}
+	if config.EjectDuration <= 0 {
+		config.EjectDuration = 30 * time.Second
+	}
+
+	pool := &Pool{
+		name:     config.Name,
+		strategy: config.Strategy,
+		config:   config,
+		breaker:  NewCircuitBreaker(config.Name, config.Breaker),
+		client:   &http.Client{Timeout: config.HealthCheckTimeout},
+		stop:     make(chan struct{}),
+	}
+
+	for _, tc := range config.Targets {
+		parsedURL, err := url.Parse(tc.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid upstream URL %q: %w", tc.URL, err)
+		}
+		if parsedURL.Scheme == "" || parsedURL.Host == "" {
+			return nil, fmt.Errorf("invalid upstream URL %q: scheme and host are required", tc.URL)
+		}
+
+		weight := tc.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+
+		pool.targets = append(pool.targets, &Target{
+			URL:     parsedURL,
+			weight:  weight,
+			healthy: true,
+		})
+	}
+
+	return pool, nil
+}
+
+// Name returns the pool name
+func (p *Pool) Name() string {
+	return p.name
+}
+
+// Breaker returns the pool's circuit breaker
+func (p *Pool) Breaker() *CircuitBreaker {
+	return p.breaker
+}
+
+// Pick selects the next target according to the pool strategy
+func (p *Pool) Pick() (*Target, error) {
+	now := time.Now()
+
+	var candidates []*Target
+	for _, t := range p.targets {
+		if t.available(now) {
+			candidates = append(candidates, t)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil, ErrNoHealthyTargets
+	}
+
+	switch p.strategy {
+	case StrategyLeastOutstanding:
+		return p.pickLeastOutstanding(candidates), nil
+	case StrategyWeighted:
+		return p.pickWeighted(candidates), nil
+	default:
+		n := atomic.AddUint64(&p.next, 1)
+		return candidates[(n-1)%uint64(len(candidates))], nil
+	}
+}
+
+// pickLeastOutstanding picks the target with the fewest in-flight requests,
+// breaking ties in round-robin order
+func (p *Pool) pickLeastOutstanding(candidates []*Target) *Target {
+	offset := int(atomic.AddUint64(&p.next, 1) % uint64(len(candidates)))
+
+	var best *Target
+	for i := range candidates {
+		t := candidates[(offset+i)%len(candidates)]
+		if best == nil || atomic.LoadInt64(&t.outstanding) < atomic.LoadInt64(&best.outstanding) {
+			best = t
+		}
+	}
+	return best
+}
+
+// pickWeighted implements smooth weighted round-robin
+func (p *Pool) pickWeighted(candidates []*Target) *Target {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	total := 0
+	var best *Target
+	for _, t := range candidates {
+		t.currentWeight += t.weight
+		total += t.weight
+		if best == nil || t.currentWeight > best.currentWeight {
+			best = t
+		}
+	}
+	best.currentWeight -= total
+	return best
+}
+
+// acquire marks the start of a request to the target
+func (t *Target) acquire() {
+	atomic.AddInt64(&t.outstanding, 1)
+	atomic.AddInt64(&t.requests, 1)
+}
+
+// release marks the end of a request to the target
+func (t *Target) release() {
+	atomic.AddInt64(&t.outstanding, -1)
+}
+
+// ReportResult records the outcome of a request for passive health checking.
+// Connection errors and 5xx responses count as failures.
+func (p *Pool) ReportResult(t *Target, statusCode int, err error) {
+	failed := err != nil || statusCode >= 500
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if !failed {
+		t.consecutiveFailures = 0
+		return
+	}
+
+	atomic.AddInt64(&t.failures, 1)
+	t.consecutiveFailures++
+
+	if p.config.EjectAfter > 0 && t.consecutiveFailures >= p.config.EjectAfter {
+		t.ejectedUntil = time.Now().Add(p.config.EjectDuration)
+		t.consecutiveFailures = 0
+		log.Printf("Ejecting upstream target %s from pool %s for %v", t.URL, p.name, p.config.EjectDuration)
+	}
+}
+
+// StartHealthChecks starts active health checking if a health check path is configured
+func (p *Pool) StartHealthChecks() {
+	if p.config.HealthCheckPath == "" {
+		return
+	}
+
+	ticker := time.NewTicker(p.config.HealthCheckInterval)
+	go func() {
+		defer ticker.Stop()
+		p.checkAll()
+		for {
+			select {
+			case <-ticker.C:
+				p.checkAll()
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops active health checking
+func (p *Pool) Stop() {
+	p.stopOnce.Do(func() {
+		close(p.stop)
+	})
+}
+
+// checkAll probes every target in the pool
+func (p *Pool) checkAll() {
+	var wg sync.WaitGroup
+	for _, t := range p.targets {
+		wg.Add(1)
+		go func(t *Target) {
+			defer wg.Done()
+			p.checkTarget(t)
+		}(t)
+	}
+	wg.Wait()
+}
+
+// checkTarget probes a single target and updates its health
+func (p *Pool) checkTarget(t *Target) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.config.HealthCheckTimeout)
+	defer cancel()
+
+	checkURL := *t.URL
+	checkURL.Path = singleJoiningSlash(t.URL.Path, p.config.HealthCheckPath)
+
+	healthy := false
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, checkURL.String(), nil)
+	if err == nil {
+		resp, err := p.client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			healthy = resp.StatusCode >= 200 && resp.StatusCode < 300
+		}
+	}
+
+	t.mutex.Lock()
+	changed := t.healthy != healthy
+	t.healthy = healthy
+	t.mutex.Unlock()
+
+	if changed {
+		log.Printf("Upstream target %s in pool %s is now healthy=%v", t.URL, p.name, healthy)
+	}
+}
+
+// Stats returns a snapshot of all targets in the pool
+func (p *Pool) Stats() []TargetStats {
+	now := time.Now()
+	result := make([]TargetStats, 0, len(p.targets))
+
+	for _, t := range p.targets {
+		t.mutex.Lock()
+		stats := TargetStats{
+			URL:         t.URL.String(),
+			Weight:      t.weight,
+			Healthy:     t.healthy,
+			Ejected:     now.Before(t.ejectedUntil),
+			Outstanding: atomic.LoadInt64(&t.outstanding),
+			Requests:    atomic.LoadInt64(&t.requests),
+			Failures:    atomic.LoadInt64(&t.failures),
+		}
+		t.mutex.Unlock()
+		result = append(result, stats)
+	}
+
+	return result
+}
+
+// Status returns a snapshot of the pool, its circuit breaker and its targets
+func (p *Pool) Status() UpstreamStatus {
+	return UpstreamStatus{
+		Name:     p.name,
+		Strategy: p.strategy,
+		Circuit:  p.breaker.Stats(),
+		Retries:  atomic.LoadInt64(&p.retries),
+		Targets:  p.Stats(),
+	}
+}
+
+// rewriteURL points a request URL at the given target, joining paths and queries
+func rewriteURL(u *url.URL, target *url.URL) {
+	u.Scheme = target.Scheme
+	u.Host = target.Host
+	u.Path = singleJoiningSlash(target.Path, u.Path)
+	if u.RawPath != "" {
+		u.RawPath = singleJoiningSlash(target.EscapedPath(), u.RawPath)
+	}
+	if target.RawQuery == "" || u.RawQuery == "" {
+		u.RawQuery = target.RawQuery + u.RawQuery
+	} else {
+		u.RawQuery = target.RawQuery + "&" + u.RawQuery
+	}
+}
+
+func singleJoiningSlash(a, b string) string {
+	aslash := strings.HasSuffix(a, "/")
+	bslash := strings.HasPrefix(b, "/")
+	switch {
+	case aslash && bslash:
+		return a + b[1:]
+	case !aslash && !bslash:
+		return a + "/" + b
+	}
+	return a + b
+}
@@ -0,0 +1,270 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Provider types understood by the adapter layer
+const (
+	ProviderOpenAI    = "openai"
+	ProviderAnthropic = "anthropic"
+)
+
+// errUnsupportedRequest is returned by adapters that cannot translate a request
+var errUnsupportedRequest = errors.New("request cannot be translated for this provider")
+
+// Adapter translates OpenAI-style chat completion requests to a provider's
+// API and the provider's responses back to the OpenAI format
+type Adapter interface {
+	// NewRequest builds the upstream request for a chat completion body, using the given model
+	NewRequest(ctx context.Context, original *http.Request, body map[string]interface{}, model string) (*http.Request, error)
+	// WriteResponse translates an upstream response and writes it to the client
+	WriteResponse(w http.ResponseWriter, resp *http.Response, stream bool, includeUsage bool) error
+}
+
+// ProviderConfig describes an upstream provider
+type ProviderConfig struct {
+	Name      string   `json:"name"`
+	Type      string   `json:"type"`        // openai or anthropic
+	Upstreams []string `json:"upstreams"`   // Base URLs of the provider's endpoints
+	APIKeyEnv string   `json:"api_key_env"` // Environment variable holding the provider API key
+}
+
+// RouteTarget is one entry in a route's fallback list
+type RouteTarget struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+}
+
+// RouteConfig maps a requested model to an ordered list of provider models
+type RouteConfig struct {
+	Model   string        `json:"model"`
+	Targets []RouteTarget `json:"targets"`
+}
+
+// RouterConfig holds the providers and routes used for failover
+type RouterConfig struct {
+	Providers []ProviderConfig `json:"providers"`
+	Routes    []RouteConfig    `json:"routes"`
+}
+
+// LoadRouterConfig reads a router configuration from a JSON file
+func LoadRouterConfig(path string) (RouterConfig, error) {
+	var config RouterConfig
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("failed to read router config: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return config, fmt.Errorf("failed to parse router config: %w", err)
+	}
+
+	return config, nil
+}
+
+// provider is a configured upstream provider with its own pool
+type provider struct {
+	name      string
+	adapter   Adapter
+	pool      *Pool
+	transport http.RoundTripper
+}
+
+// Router sends chat completions for routed models to an ordered list of
+// providers, failing over when a provider is unavailable or rate limiting
+type Router struct {
+	providers map[string]*provider
+	routes    map[string][]RouteTarget
+	pools     []*Pool
+}
+
+// NewRouter creates a router. Each provider gets its own upstream pool built
+// from poolDefaults, so retries, ejection and circuit breaking apply per
+// provider; active health checks are not used for provider pools.
+func NewRouter(config RouterConfig, poolDefaults PoolConfig) (*Router, error) {
+	router := &Router{
+		providers: make(map[string]*provider),
+		routes:    make(map[string][]RouteTarget),
+	}
+
+	for _, pc := range config.Providers {
+		if pc.Name == "" {
+			return nil, errors.New("provider name is required")
+		}
+		if _, exists := router.providers[pc.Name]; exists {
+			return nil, fmt.Errorf("duplicate provider: %s", pc.Name)
+		}
+
+		apiKey := ""
+		if pc.APIKeyEnv != "" {
+			apiKey = os.Getenv(pc.APIKeyEnv)
+		}
+
+		var adapter Adapter
+		switch pc.Type {
+		case ProviderOpenAI:
+			adapter = &openAIAdapter{apiKey: apiKey}
+		case ProviderAnthropic:
+			adapter = &anthropicAdapter{apiKey: apiKey}
+		default:
+			return nil, fmt.Errorf("unknown provider type %q for provider %s", pc.Type, pc.Name)
+		}
+
+		poolConfig := poolDefaults
+		poolConfig.Name = pc.Name
+		poolConfig.Targets = nil
+		poolConfig.HealthCheckPath = ""
+		for _, u := range pc.Upstreams {
+			poolConfig.Targets = append(poolConfig.Targets, TargetConfig{URL: u, Weight: 1})
+		}
+
+		pool, err := NewPool(poolConfig)
+		if err != nil {
+			return nil, fmt.Errorf("provider %s: %w", pc.Name, err)
+		}
+
+		router.providers[pc.Name] = &provider{
+			name:      pc.Name,
+			adapter:   adapter,
+			pool:      pool,
+			transport: &upstreamTransport{pool: pool, base: http.DefaultTransport},
+		}
+		router.pools = append(router.pools, pool)
+	}
+
+	for _, rc := range config.Routes {
+		if len(rc.Targets) == 0 {
+			return nil, fmt.Errorf("route %s has no targets", rc.Model)
+		}
+		for _, target := range rc.Targets {
+			if _, exists := router.providers[target.Provider]; !exists {
+				return nil, fmt.Errorf("route %s references unknown provider %s", rc.Model, target.Provider)
+			}
+		}
+		router.routes[rc.Model] = rc.Targets
+	}
+
+	return router, nil
+}
+
+// Pools returns the upstream pools of all providers
+func (rt *Router) Pools() []*Pool {
+	return rt.pools
+}
+
+// Route returns the fallback list for a model, if it is routed
+func (rt *Router) Route(model string) ([]RouteTarget, bool) {
+	targets, exists := rt.routes[model]
+	return targets, exists
+}
+
+// Serve sends a chat completion to each target in order until one succeeds,
+// writing the translated response. It returns the name of the serving provider.
+func (rt *Router) Serve(w http.ResponseWriter, r *http.Request, body map[string]interface{}, targets []RouteTarget) (string, error) {
+	stream, _ := body["stream"].(bool)
+	includeUsage := false
+	if options, ok := body["stream_options"].(map[string]interface{}); ok {
+		includeUsage, _ = options["include_usage"].(bool)
+	}
+
+	var lastErr error
+	for i, target := range targets {
+		p := rt.providers[target.Provider]
+
+		outReq, err := p.adapter.NewRequest(r.Context(), r, body, target.Model)
+		if err != nil {
+			lastErr = fmt.Errorf("provider %s: %w", p.name, err)
+			continue
+		}
+
+		resp, err := p.transport.RoundTrip(outReq)
+		if err != nil {
+			lastErr = fmt.Errorf("provider %s: %w", p.name, err)
+			log.Printf("Provider %s failed for model %s: %v", p.name, target.Model, err)
+			continue
+		}
+
+		if shouldFailover(resp.StatusCode) && i < len(targets)-1 {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+			lastErr = fmt.Errorf("provider %s returned %d", p.name, resp.StatusCode)
+			log.Printf("Provider %s returned %d for model %s, failing over", p.name, resp.StatusCode, target.Model)
+			continue
+		}
+
+		w.Header().Set(ProviderHeader, p.name)
+		trackUsage(resp, requestInfoFrom(r.Context()))
+		err = p.adapter.WriteResponse(w, resp, stream, includeUsage)
+		resp.Body.Close()
+		return p.name, err
+	}
+
+	if lastErr == nil {
+		lastErr = errors.New("no providers available")
+	}
+	return "", lastErr
+}
+
+// ProviderHeader tells clients which provider served a request
+const ProviderHeader = "X-FlowGuard-Provider"
+
+// shouldFailover reports whether a provider response warrants trying the next provider
+func shouldFailover(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
+
+// hopHeaders are not forwarded between connections
+var hopHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization",
+	"Te", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+// copyResponse writes an upstream response to the client unchanged, flushing as data arrives
+func copyResponse(w http.ResponseWriter, resp *http.Response) error {
+	for key, values := range resp.Header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	for _, key := range hopHeaders {
+		w.Header().Del(key)
+	}
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(resp.StatusCode)
+
+	controller := http.NewResponseController(w)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return werr
+			}
+			controller.Flush()
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// isChatCompletion reports whether a request is an OpenAI-style chat completion
+func isChatCompletion(r *http.Request) bool {
+	return r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/chat/completions")
+}
@@ -0,0 +1,72 @@
+package proxy
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"flowguard/internal/limiter"
+)
+
+// parseUpstreamSignal extracts the provider's rate limit state from a response.
+// Both OpenAI (x-ratelimit-*) and Anthropic (anthropic-ratelimit-*) headers are understood.
+func parseUpstreamSignal(resp *http.Response) limiter.UpstreamSignal {
+	signal := limiter.UpstreamSignal{
+		StatusCode:        resp.StatusCode,
+		LimitRequests:     -1,
+		RemainingRequests: -1,
+		LimitTokens:       -1,
+		RemainingTokens:   -1,
+	}
+
+	h := resp.Header
+	if h.Get("X-Ratelimit-Remaining-Requests") != "" || h.Get("X-Ratelimit-Remaining-Tokens") != "" {
+		signal.LimitRequests = headerInt(h, "X-Ratelimit-Limit-Requests")
+		signal.RemainingRequests = headerInt(h, "X-Ratelimit-Remaining-Requests")
+		signal.ResetRequests = headerDuration(h, "X-Ratelimit-Reset-Requests")
+		signal.LimitTokens = headerInt(h, "X-Ratelimit-Limit-Tokens")
+		signal.RemainingTokens = headerInt(h, "X-Ratelimit-Remaining-Tokens")
+		signal.ResetTokens = headerDuration(h, "X-Ratelimit-Reset-Tokens")
+	} else {
+		signal.LimitRequests = headerInt(h, "Anthropic-Ratelimit-Requests-Limit")
+		signal.RemainingRequests = headerInt(h, "Anthropic-Ratelimit-Requests-Remaining")
+		signal.ResetRequests = headerTimeUntil(h, "Anthropic-Ratelimit-Requests-Reset")
+		signal.LimitTokens = headerInt(h, "Anthropic-Ratelimit-Tokens-Limit")
+		signal.RemainingTokens = headerInt(h, "Anthropic-Ratelimit-Tokens-Remaining")
+		signal.ResetTokens = headerTimeUntil(h, "Anthropic-Ratelimit-Tokens-Reset")
+	}
+
+	if delay, ok := parseRetryAfter(h.Get("Retry-After")); ok {
+		signal.RetryAfter = delay
+	}
+
+	return signal
+}
+
+// headerInt parses an integer header, returning -1 when it is missing or invalid
+func headerInt(h http.Header, key string) int64 {
+	value, err := strconv.ParseInt(strings.TrimSpace(h.Get(key)), 10, 64)
+	if err != nil {
+		return -1
+	}
+	return value
+}
+
+// headerDuration parses a Go-style duration header such as "6m0s" or "20ms"
+func headerDuration(h http.Header, key string) time.Duration {
+	value, err := time.ParseDuration(strings.TrimSpace(h.Get(key)))
+	if err != nil || value < 0 {
+		return 0
+	}
+	return value
+}
+
+// headerTimeUntil parses an RFC 3339 timestamp header into the time remaining until it
+func headerTimeUntil(h http.Header, key string) time.Duration {
+	value, err := time.Parse(time.RFC3339, strings.TrimSpace(h.Get(key)))
+	if err != nil {
+		return 0
+	}
+	return max(time.Until(value), 0)
+}
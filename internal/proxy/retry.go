@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryableHeader lets clients opt non-idempotent requests into retries
+const RetryableHeader = "X-Retryable"
+
+// RetryPolicy controls how failed upstream requests are retried
+type RetryPolicy struct {
+	MaxRetries   int           // Retries after the first attempt (0 disables retries)
+	BaseBackoff  time.Duration // Backoff before the first retry
+	MaxBackoff   time.Duration // Upper bound for backoff and honored Retry-After delays
+	MaxBodyBytes int64         // Largest request body buffered for replay
+}
+
+// canRetry reports whether a request is safe to send more than once
+func (p RetryPolicy) canRetry(req *http.Request) bool {
+	if p.MaxRetries <= 0 {
+		return false
+	}
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete, http.MethodTrace:
+		return true
+	}
+
+	retryable, _ := strconv.ParseBool(req.Header.Get(RetryableHeader))
+	return retryable
+}
+
+// retryDelay decides whether an attempt should be retried and how long to wait first
+func (p RetryPolicy) retryDelay(attempt int, resp *http.Response, err error) (time.Duration, bool) {
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrNoHealthyTargets) {
+			return 0, false
+		}
+		return p.backoff(attempt), true
+	}
+
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		if delay, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			return delay, delay <= p.MaxBackoff
+		}
+		return p.backoff(attempt), true
+	case http.StatusTooManyRequests:
+		if delay, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			return delay, delay <= p.MaxBackoff
+		}
+		return 0, false
+	}
+
+	return 0, false
+}
+
+// backoff returns an exponential backoff with full jitter for the given attempt
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	ceiling := p.BaseBackoff << attempt
+	if ceiling <= 0 || ceiling > p.MaxBackoff {
+		ceiling = p.MaxBackoff
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+
+	return 0, false
+}
+
+// sleepContext waits for the given duration or until the context is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,139 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// RewriteRule rewrites the JSON body of requests whose model matches Alias
+type RewriteRule struct {
+	Alias     string                 `json:"alias"`                // Requested model to match ("*" matches any model)
+	Model     string                 `json:"model,omitempty"`      // Concrete model substituted for the alias
+	MaxTokens *int64                 `json:"max_tokens,omitempty"` // Upper bound for max_tokens / max_completion_tokens
+	Defaults  map[string]interface{} `json:"defaults,omitempty"`   // Parameters set when the request omits them
+	ForceUser bool                   `json:"force_user,omitempty"` // Set the user field to the client ID
+}
+
+// RewriteConfig holds global and per-client rewrite rules
+type RewriteConfig struct {
+	Global  []RewriteRule            `json:"global"`
+	Clients map[string][]RewriteRule `json:"clients"`
+}
+
+// LoadRewriteConfig reads rewrite rules from a JSON file
+func LoadRewriteConfig(path string) (RewriteConfig, error) {
+	var config RewriteConfig
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("failed to read rewrite rules: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return config, fmt.Errorf("failed to parse rewrite rules: %w", err)
+	}
+
+	return config, nil
+}
+
+// Rewriter applies model aliasing and request rewriting rules.
+// A client's own rules take precedence over global rules, and only the
+// first matching rule is applied.
+type Rewriter struct {
+	global  []RewriteRule
+	clients map[string][]RewriteRule
+}
+
+// NewRewriter creates a rewriter from a rule configuration
+func NewRewriter(config RewriteConfig) (*Rewriter, error) {
+	check := func(rules []RewriteRule, scope string) error {
+		for _, rule := range rules {
+			if rule.Alias == "" {
+				return fmt.Errorf("%s rewrite rule is missing an alias", scope)
+			}
+			if rule.MaxTokens != nil && *rule.MaxTokens <= 0 {
+				return fmt.Errorf("%s rewrite rule for %s has a non-positive max_tokens", scope, rule.Alias)
+			}
+		}
+		return nil
+	}
+
+	if err := check(config.Global, "global"); err != nil {
+		return nil, err
+	}
+	for clientID, rules := range config.Clients {
+		if err := check(rules, "client "+clientID); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Rewriter{
+		global:  config.Global,
+		clients: config.Clients,
+	}, nil
+}
+
+// Apply rewrites a request body in place and reports whether it changed
+func (rw *Rewriter) Apply(clientID string, body map[string]interface{}) bool {
+	model, _ := body["model"].(string)
+
+	rule, found := matchRule(rw.clients[clientID], model)
+	if !found {
+		rule, found = matchRule(rw.global, model)
+	}
+	if !found {
+		return false
+	}
+
+	changed := false
+	set := func(key string, value interface{}) {
+		body[key] = value
+		changed = true
+	}
+
+	if rule.Model != "" && rule.Model != model {
+		set("model", rule.Model)
+	}
+
+	for key, value := range rule.Defaults {
+		if _, exists := body[key]; !exists {
+			set(key, value)
+		}
+	}
+
+	if rule.MaxTokens != nil {
+		limit := float64(*rule.MaxTokens)
+		clamped := false
+		for _, key := range []string{"max_tokens", "max_completion_tokens"} {
+			if value, exists := body[key]; exists {
+				clamped = true
+				if n, ok := value.(float64); !ok || n > limit {
+					set(key, *rule.MaxTokens)
+				}
+			}
+		}
+		if !clamped {
+			set("max_tokens", *rule.MaxTokens)
+		}
+	}
+
+	if rule.ForceUser && body["user"] != clientID {
+		set("user", clientID)
+	}
+
+	return changed
+}
+
+// matchRule returns the first rule matching a model
+func matchRule(rules []RewriteRule, model string) (RewriteRule, bool) {
+	for _, rule := range rules {
+		if rule.Alias == model || rule.Alias == "*" {
+			return rule, true
+		}
+	}
+	return RewriteRule{}, false
+}
@@ -0,0 +1,171 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"flowguard/internal/tracing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// upstreamTransport is an http.RoundTripper that sends each attempt to a target
+// picked from a pool, retrying failed attempts according to the retry policy and
+// failing fast while the pool's circuit breaker is open
+type upstreamTransport struct {
+	pool *Pool
+	base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *upstreamTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	breaker := t.pool.Breaker()
+	policy := t.pool.config.Retry
+
+	maxAttempts := 1
+	var body []byte
+	if policy.canRetry(req) {
+		buffered, replayable, err := bufferBody(req, policy.MaxBodyBytes)
+		if err != nil {
+			return nil, err
+		}
+		body = buffered
+		if replayable {
+			maxAttempts += policy.MaxRetries
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := breaker.Allow(); err != nil {
+			return nil, err
+		}
+
+		resp, err := t.roundTripOnce(req, body, attempt)
+		if countsForBreaker(err) {
+			breaker.Record(err == nil && resp.StatusCode < 500)
+		} else {
+			breaker.Release()
+		}
+
+		if attempt+1 >= maxAttempts {
+			return resp, err
+		}
+
+		delay, retry := policy.retryDelay(attempt, resp, err)
+		if !retry {
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+
+		atomic.AddInt64(&t.pool.retries, 1)
+		if err := sleepContext(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// countsForBreaker reports whether an attempt's outcome says anything about the
+// upstream's health. Cancelled or timed out requests and an empty pool are not
+// upstream failures, so they must not trip the breaker
+func countsForBreaker(err error) bool {
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, ErrNoHealthyTargets)
+}
+
+// roundTripOnce sends a single attempt to the next target in the pool.
+// Each attempt gets its own client span, which is propagated to the upstream
+// and ends once the response headers arrive.
+func (t *upstreamTransport) roundTripOnce(req *http.Request, body []byte, attempt int) (*http.Response, error) {
+	ctx, span := tracing.Tracer().Start(req.Context(), "flowguard.upstream",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			tracing.AttrProvider.String(t.pool.Name()),
+			tracing.AttrAttempt.Int(attempt+1),
+		),
+	)
+	defer span.End()
+
+	target, err := t.pool.Pick()
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(tracing.AttrUpstream.String(target.URL.String()))
+	requestInfoFrom(ctx).setTarget(target.URL.String())
+
+	outReq := req.Clone(ctx)
+	if body != nil {
+		outReq.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	rewriteURL(outReq.URL, target.URL)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(outReq.Header))
+
+	target.acquire()
+	resp, err := t.base.RoundTrip(outReq)
+	if err != nil {
+		target.release()
+		t.pool.ReportResult(target, 0, err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= 500 {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+
+	t.pool.ReportResult(target, resp.StatusCode, nil)
+	resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: target.release}
+	return resp, nil
+}
+
+// bufferBody reads the request body into memory so it can be replayed on retries.
+// Bodies larger than maxBytes are left streaming and reported as not replayable.
+func bufferBody(req *http.Request, maxBytes int64) ([]byte, bool, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, true, nil
+	}
+
+	buffered, err := io.ReadAll(io.LimitReader(req.Body, maxBytes+1))
+	if err != nil {
+		return nil, false, err
+	}
+
+	if int64(len(buffered)) > maxBytes {
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(buffered), req.Body), req.Body}
+		return nil, false, nil
+	}
+
+	req.Body.Close()
+	return buffered, true, nil
+}
+
+// releaseOnClose releases a target once the response body has been closed,
+// so that streaming responses count as outstanding until they finish
+type releaseOnClose struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (r *releaseOnClose) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return err
+}
@@ -0,0 +1,197 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// maxUsageBody bounds how much of a JSON response is buffered to read its usage
+const maxUsageBody = 1 << 20
+
+// requestInfo collects details about a request as it passes through the proxy
+type requestInfo struct {
+	mutex        sync.Mutex
+	target       string // Upstream target that served the request
+	actualTokens int64  // Tokens reported in the upstream's usage, -1 when unknown
+}
+
+type requestInfoKey struct{}
+
+// withRequestInfo attaches a new requestInfo to a request's context
+func withRequestInfo(r *http.Request) (*http.Request, *requestInfo) {
+	info := &requestInfo{actualTokens: -1}
+	return r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, info)), info
+}
+
+// requestInfoFrom returns the requestInfo of a context, or nil
+func requestInfoFrom(ctx context.Context) *requestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(*requestInfo)
+	return info
+}
+
+func (ri *requestInfo) setTarget(target string) {
+	if ri == nil {
+		return
+	}
+	ri.mutex.Lock()
+	ri.target = target
+	ri.mutex.Unlock()
+}
+
+func (ri *requestInfo) setActualTokens(tokens int64) {
+	if ri == nil {
+		return
+	}
+	ri.mutex.Lock()
+	ri.actualTokens = tokens
+	ri.mutex.Unlock()
+}
+
+// Target returns the upstream target that served the request
+func (ri *requestInfo) Target() string {
+	ri.mutex.Lock()
+	defer ri.mutex.Unlock()
+	return ri.target
+}
+
+// ActualTokens returns the tokens reported by the upstream, or -1 when unknown
+func (ri *requestInfo) ActualTokens() int64 {
+	ri.mutex.Lock()
+	defer ri.mutex.Unlock()
+	return ri.actualTokens
+}
+
+// usageReader passes a response body through while reading the token usage
+// reported in it, from a JSON body or from the events of an SSE stream.
+// Both OpenAI (prompt/completion) and Anthropic (input/output) fields are understood.
+type usageReader struct {
+	io.ReadCloser
+	info   *requestInfo
+	stream bool
+	buf    bytes.Buffer
+	skip   bool // Set when a JSON body is too large to buffer
+	input  int64
+	output int64
+	total  int64
+	once   sync.Once
+}
+
+// trackUsage wraps a response body so its token usage is recorded in the
+// request's info once the body has been read
+func trackUsage(resp *http.Response, info *requestInfo) {
+	if info == nil {
+		return
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	stream := strings.HasPrefix(contentType, "text/event-stream")
+	if !stream && !strings.Contains(contentType, "json") {
+		return
+	}
+
+	resp.Body = &usageReader{ReadCloser: resp.Body, info: info, stream: stream}
+}
+
+func (u *usageReader) Read(p []byte) (int, error) {
+	n, err := u.ReadCloser.Read(p)
+	if n > 0 {
+		u.consume(p[:n])
+	}
+	if err == io.EOF {
+		u.finish()
+	}
+	return n, err
+}
+
+func (u *usageReader) Close() error {
+	u.finish()
+	return u.ReadCloser.Close()
+}
+
+// consume buffers response data; complete SSE lines are parsed as they arrive
+func (u *usageReader) consume(data []byte) {
+	if !u.stream {
+		if u.skip || u.buf.Len()+len(data) > maxUsageBody {
+			u.skip = true
+			return
+		}
+		u.buf.Write(data)
+		return
+	}
+
+	u.buf.Write(data)
+	for {
+		line, err := u.buf.ReadBytes('\n')
+		if err != nil {
+			// Keep the partial line for the next read
+			rest := append([]byte(nil), line...)
+			u.buf.Reset()
+			u.buf.Write(rest)
+			return
+		}
+		u.parseEvent(line)
+	}
+}
+
+// parseEvent reads the usage from an SSE data line
+func (u *usageReader) parseEvent(line []byte) {
+	payload, found := bytes.CutPrefix(bytes.TrimSpace(line), []byte("data:"))
+	if !found || !bytes.Contains(payload, []byte(`"usage"`)) {
+		return
+	}
+
+	var event map[string]interface{}
+	if json.Unmarshal(payload, &event) == nil {
+		u.observe(event)
+	}
+}
+
+// observe records the usage object of a response or event
+func (u *usageReader) observe(object map[string]interface{}) {
+	usage, ok := object["usage"].(map[string]interface{})
+	if !ok {
+		// Anthropic message_start events nest usage inside the message
+		message, _ := object["message"].(map[string]interface{})
+		if usage, ok = message["usage"].(map[string]interface{}); !ok {
+			return
+		}
+	}
+
+	count := func(keys ...string) int64 {
+		for _, key := range keys {
+			if n, ok := usage[key].(float64); ok {
+				return int64(n)
+			}
+		}
+		return 0
+	}
+
+	u.total = max(u.total, count("total_tokens"))
+	u.input = max(u.input, count("prompt_tokens", "input_tokens"))
+	u.output = max(u.output, count("completion_tokens", "output_tokens"))
+}
+
+// finish records the usage once the body has been read or closed
+func (u *usageReader) finish() {
+	u.once.Do(func() {
+		if !u.stream && !u.skip && u.buf.Len() > 0 {
+			var body map[string]interface{}
+			if json.Unmarshal(u.buf.Bytes(), &body) == nil {
+				u.observe(body)
+			}
+		}
+
+		tokens := u.total
+		if tokens == 0 {
+			tokens = u.input + u.output
+		}
+		if tokens > 0 {
+			u.info.setActualTokens(tokens)
+		}
+	})
+}
@@ -0,0 +1,94 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Exporter types
+const (
+	ExporterNone = "none"
+	ExporterOTLP = "otlp"
+)
+
+// Span attribute keys shared by the proxy spans
+const (
+	AttrClientID      = attribute.Key("flowguard.client_id")
+	AttrModel         = attribute.Key("flowguard.model")
+	AttrTokenEstimate = attribute.Key("flowguard.token_estimate")
+	AttrActualTokens  = attribute.Key("flowguard.actual_tokens")
+	AttrDecision      = attribute.Key("flowguard.limiter.decision")
+	AttrUpstream      = attribute.Key("flowguard.upstream.target")
+	AttrProvider      = attribute.Key("flowguard.upstream.provider")
+	AttrAttempt       = attribute.Key("flowguard.upstream.attempt")
+)
+
+// Config holds tracing settings
+type Config struct {
+	Exporter    string // none or otlp
+	Endpoint    string // OTLP/HTTP endpoint URL, e.g. http://localhost:4318
+	ServiceName string
+	SampleRatio float64 // Fraction of new traces to sample; parent decisions are respected
+}
+
+// Tracer returns the tracer used by FlowGuard components
+func Tracer() trace.Tracer {
+	return otel.Tracer("flowguard")
+}
+
+// Setup installs the global tracer provider and W3C trace context propagator.
+// The returned function flushes and stops the exporter.
+func Setup(ctx context.Context, config Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	switch config.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+	default:
+		return nil, fmt.Errorf("unknown trace exporter: %s", config.Exporter)
+	}
+
+	var options []otlptracehttp.Option
+	if config.Endpoint != "" {
+		options = append(options, otlptracehttp.WithEndpointURL(config.Endpoint))
+	}
+
+	exporter, err := otlptracehttp.New(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	provider := NewProvider(config, exporter)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// NewProvider creates a tracer provider that batches spans to the given
+// exporter. Tests export synchronously to an in-memory exporter instead
+// (sdk/trace/tracetest with sdktrace.WithSyncer), so spans can be checked
+// as soon as a request completes.
+func NewProvider(config Config, exporter sdktrace.SpanExporter) *sdktrace.TracerProvider {
+	serviceName := config.ServiceName
+	if serviceName == "" {
+		serviceName = "flowguard"
+	}
+
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(serviceName))),
+	)
+}
@@ -0,0 +1,119 @@
+package types
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// QuantileSketch estimates quantiles of a stream of positive values with
+// bounded relative error, using logarithmically sized buckets (as in DDSketch).
+// Memory is bounded by collapsing the lowest buckets once maxBuckets is reached.
+type QuantileSketch struct {
+	gamma      float64
+	logGamma   float64
+	maxBuckets int
+	buckets    map[int]uint64
+	zeroCount  uint64
+	count      uint64
+	sum        float64
+	mutex      sync.Mutex
+}
+
+// minSketchValue is the smallest value tracked in its own bucket
+const minSketchValue = 1e-3
+
+// NewQuantileSketch creates a sketch with the given relative accuracy (e.g. 0.01 for 1%)
+func NewQuantileSketch(relativeAccuracy float64) *QuantileSketch {
+	if relativeAccuracy <= 0 || relativeAccuracy >= 1 {
+		relativeAccuracy = 0.01
+	}
+	gamma := (1 + relativeAccuracy) / (1 - relativeAccuracy)
+	return &QuantileSketch{
+		gamma:      gamma,
+		logGamma:   math.Log(gamma),
+		maxBuckets: 2048,
+		buckets:    make(map[int]uint64),
+	}
+}
+
+// Add records a value
+func (s *QuantileSketch) Add(value float64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.count++
+	s.sum += value
+
+	if value <= minSketchValue {
+		s.zeroCount++
+		return
+	}
+
+	s.buckets[int(math.Ceil(math.Log(value)/s.logGamma))]++
+	if len(s.buckets) > s.maxBuckets {
+		s.collapse()
+	}
+}
+
+// Quantile returns the estimated value at quantile q (0 <= q <= 1), or 0 if empty
+func (s *QuantileSketch) Quantile(q float64) float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.count == 0 {
+		return 0
+	}
+
+	rank := uint64(q * float64(s.count-1))
+	if rank < s.zeroCount {
+		return 0
+	}
+
+	keys := s.sortedKeys()
+	seen := s.zeroCount
+	for _, key := range keys {
+		seen += s.buckets[key]
+		if seen > rank {
+			// Midpoint of the bucket (gamma^(key-1), gamma^key] in relative terms
+			return 2 * math.Pow(s.gamma, float64(key)) / (1 + s.gamma)
+		}
+	}
+
+	return 2 * math.Pow(s.gamma, float64(keys[len(keys)-1])) / (1 + s.gamma)
+}
+
+// Mean returns the mean of all recorded values
+func (s *QuantileSketch) Mean() float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.count == 0 {
+		return 0
+	}
+	return s.sum / float64(s.count)
+}
+
+// Count returns the number of recorded values
+func (s *QuantileSketch) Count() uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.count
+}
+
+// collapse merges the two lowest buckets. Must be called with the mutex held.
+func (s *QuantileSketch) collapse() {
+	keys := s.sortedKeys()
+	s.buckets[keys[1]] += s.buckets[keys[0]]
+	delete(s.buckets, keys[0])
+}
+
+// sortedKeys returns the bucket indexes in ascending order. Must be called with the mutex held.
+func (s *QuantileSketch) sortedKeys() []int {
+	keys := make([]int, 0, len(s.buckets))
+	for key := range s.buckets {
+		keys = append(keys, key)
+	}
+	sort.Ints(keys)
+	return keys
+}
@@ -7,10 +7,194 @@ import (
 
 // ClientConfig holds the rate limiting configuration for a specific client
 type ClientConfig struct {
-	ClientID string  `json:"client_id"`
-	RPM      *int64  `json:"rpm,omitempty"`      // Requests per minute (nil means no limit)
-	TPM      *int64  `json:"tpm,omitempty"`      // Tokens per minute (nil means no limit)
-	Enabled  bool    `json:"enabled"`            // Whether rate limiting is enabled for this client
+	ClientID    string    `json:"client_id"`
+	Template    string    `json:"template,omitempty"`     // Limit template supplying the limits not set here
+	RPM         *int64    `json:"rpm,omitempty"`          // Requests per minute (nil means the template's limit, or no limit)
+	TPM         *int64    `json:"tpm,omitempty"`          // Tokens per minute (nil means the template's limit, or no limit)
+	Enabled     bool      `json:"enabled"`                // Whether rate limiting is enabled for this client
+	Mode        string    `json:"mode,omitempty"`         // How limits are applied: enforce (default), shadow or off
+	Version     int64     `json:"version"`                // Incremented on every change, assigned by the manager
+	UpdatedAt   time.Time `json:"updated_at"`             // Time this version was created
+	AutoCreated bool      `json:"auto_created,omitempty"` // Created on first use and may be evicted, assigned by the manager
+	Rule        string    `json:"rule,omitempty"`         // Client rule the client was created for, assigned by the manager
+	Schedule    *Schedule `json:"schedule,omitempty"`     // Limits that apply during recurring time windows
+}
+
+// Enforcement modes of a client's limits
+const (
+	ModeEnforce = "enforce" // Requests over the limits are rejected
+	ModeShadow  = "shadow"  // Requests over the limits are forwarded and counted as shadow drops
+	ModeOff     = "off"     // Limits are not evaluated
+)
+
+// EnforcementMode returns how the client's limits are applied: ModeOff if
+// rate limiting is disabled, and ModeEnforce if no mode is set
+func (c *ClientConfig) EnforcementMode() string {
+	switch {
+	case !c.Enabled:
+		return ModeOff
+	case c.Mode == "":
+		return ModeEnforce
+	}
+	return c.Mode
+}
+
+// Clone returns a deep copy of the configuration
+func (c *ClientConfig) Clone() *ClientConfig {
+	if c == nil {
+		return nil
+	}
+
+	copied := *c
+	if c.RPM != nil {
+		rpm := *c.RPM
+		copied.RPM = &rpm
+	}
+	if c.TPM != nil {
+		tpm := *c.TPM
+		copied.TPM = &tpm
+	}
+	copied.Schedule = c.Schedule.Clone()
+	return &copied
+}
+
+// Schedule changes a client's limits during recurring windows of the week
+type Schedule struct {
+	Timezone string           `json:"timezone,omitempty"` // IANA time zone of the windows, UTC if empty
+	Windows  []ScheduleWindow `json:"windows"`            // The first window containing the current time applies
+}
+
+// ScheduleWindow is a daily time range, on some days of the week, with the
+// limits that apply during it. Limits it leaves unset are not changed.
+type ScheduleWindow struct {
+	Name  string   `json:"name"`
+	Days  []string `json:"days,omitempty"` // mon, tue, wed, thu, fri, sat or sun; every day if empty
+	Start string   `json:"start"`          // HH:MM, inclusive
+	End   string   `json:"end"`            // HH:MM, exclusive; before start for windows that cross midnight
+	RPM   *int64   `json:"rpm,omitempty"`  // Requests per minute during the window
+	TPM   *int64   `json:"tpm,omitempty"`  // Tokens per minute during the window
+}
+
+// Clone returns a deep copy of the schedule
+func (s *Schedule) Clone() *Schedule {
+	if s == nil {
+		return nil
+	}
+
+	copied := *s
+	copied.Windows = make([]ScheduleWindow, len(s.Windows))
+	for i, window := range s.Windows {
+		window.Days = append([]string(nil), window.Days...)
+		if window.RPM != nil {
+			rpm := *window.RPM
+			window.RPM = &rpm
+		}
+		if window.TPM != nil {
+			tpm := *window.TPM
+			window.TPM = &tpm
+		}
+		copied.Windows[i] = window
+	}
+	return &copied
+}
+
+// LimitTemplate is a named set of limits that clients can reference instead
+// of setting their own. Changes to a template apply to every client using it.
+type LimitTemplate struct {
+	Name      string    `json:"name"`
+	RPM       *int64    `json:"rpm,omitempty"` // Requests per minute (nil means no limit)
+	TPM       *int64    `json:"tpm,omitempty"` // Tokens per minute (nil means no limit)
+	UpdatedAt time.Time `json:"updated_at"`    // Time the template was last changed, assigned by the manager
+}
+
+// Clone returns a deep copy of the template
+func (t *LimitTemplate) Clone() *LimitTemplate {
+	if t == nil {
+		return nil
+	}
+
+	copied := *t
+	if t.RPM != nil {
+		rpm := *t.RPM
+		copied.RPM = &rpm
+	}
+	if t.TPM != nil {
+		tpm := *t.TPM
+		copied.TPM = &tpm
+	}
+	return &copied
+}
+
+// ClientRule sets the limits of clients without their own configuration whose
+// IDs match a pattern. Matching clients get their own buckets, or share one
+// set of buckets held by the client named after the rule.
+type ClientRule struct {
+	Name      string    `json:"name"`
+	Pattern   string    `json:"pattern"`            // Glob, in which * does not match /, or a regular expression
+	Regex     bool      `json:"regex,omitempty"`    // Pattern is a regular expression matching the whole client ID
+	Priority  int       `json:"priority"`           // Rules are evaluated in ascending priority, then by name
+	Shared    bool      `json:"shared,omitempty"`   // Matching clients share the buckets of the group client
+	Template  string    `json:"template,omitempty"` // Limit template supplying the limits not set here
+	RPM       *int64    `json:"rpm,omitempty"`      // Requests per minute (nil means the template's limit, or no limit)
+	TPM       *int64    `json:"tpm,omitempty"`      // Tokens per minute (nil means the template's limit, or no limit)
+	UpdatedAt time.Time `json:"updated_at"`         // Time the rule was last changed, assigned by the manager
+}
+
+// Clone returns a deep copy of the rule
+func (r *ClientRule) Clone() *ClientRule {
+	if r == nil {
+		return nil
+	}
+
+	copied := *r
+	if r.RPM != nil {
+		rpm := *r.RPM
+		copied.RPM = &rpm
+	}
+	if r.TPM != nil {
+		tpm := *r.TPM
+		copied.TPM = &tpm
+	}
+	return &copied
+}
+
+// LimitOverride temporarily changes a client's limits, for example during an
+// incident. Each limit is either replaced or adjusted by a delta; limits it
+// does not change are left as they are.
+type LimitOverride struct {
+	RPM       *int64    `json:"rpm,omitempty"`       // Replaces the requests per minute
+	TPM       *int64    `json:"tpm,omitempty"`       // Replaces the tokens per minute
+	RPMDelta  int64     `json:"rpm_delta,omitempty"` // Added to the requests per minute; no effect without a limit
+	TPMDelta  int64     `json:"tpm_delta,omitempty"` // Added to the tokens per minute; no effect without a limit
+	Reason    string    `json:"reason"`
+	Actor     string    `json:"actor,omitempty"` // Operator who set the override
+	CreatedAt time.Time `json:"created_at"`      // Assigned by the manager
+	ExpiresAt time.Time `json:"expires_at"`      // The override is removed at this time, assigned by the manager
+}
+
+// Clone returns a deep copy of the override
+func (o *LimitOverride) Clone() *LimitOverride {
+	if o == nil {
+		return nil
+	}
+
+	copied := *o
+	if o.RPM != nil {
+		rpm := *o.RPM
+		copied.RPM = &rpm
+	}
+	if o.TPM != nil {
+		tpm := *o.TPM
+		copied.TPM = &tpm
+	}
+	return &copied
+}
+
+// Limits are the limits enforced for a client once its schedule, rule, template and override are applied
+type Limits struct {
+	RPM            *int64 `json:"rpm,omitempty"`             // nil means no limit
+	TPM            *int64 `json:"tpm,omitempty"`             // nil means no limit
+	ScheduleWindow string `json:"schedule_window,omitempty"` // Name of the active schedule window
 }
 
 // ClientStats holds runtime statistics for a client
@@ -21,11 +205,27 @@ type ClientStats struct {
 	DroppedRequests  int64     `json:"dropped_requests"`
 	RPMDropped       int64     `json:"rpm_dropped"`
 	TPMDropped       int64     `json:"tpm_dropped"`
+	UpstreamDropped  int64     `json:"upstream_dropped"`
 	TokensUsed       int64     `json:"tokens_used"`
 	RPMRemaining     int64     `json:"rpm_remaining"`
 	TPMRemaining     int64     `json:"tpm_remaining"`
 	LastRequestTime  time.Time `json:"last_request_time"`
 	AvgLatencyMs     float64   `json:"avg_latency_ms"`
+	LatencyP50Ms     float64   `json:"latency_p50_ms"`  // End-to-end latency percentiles
+	LatencyP90Ms     float64   `json:"latency_p90_ms"`
+	LatencyP99Ms     float64   `json:"latency_p99_ms"`
+	TTFBP50Ms        float64   `json:"ttfb_p50_ms"`     // Time to first byte percentiles
+	TTFBP90Ms        float64   `json:"ttfb_p90_ms"`
+	TTFBP99Ms        float64   `json:"ttfb_p99_ms"`
+	UpstreamP50Ms    float64   `json:"upstream_p50_ms"` // Upstream-only latency percentiles
+	UpstreamP90Ms    float64   `json:"upstream_p90_ms"`
+	UpstreamP99Ms    float64   `json:"upstream_p99_ms"`
+	ProviderRequests      map[string]int64 `json:"provider_requests,omitempty"` // Requests served per upstream provider
+	ScheduleWindow        string           `json:"schedule_window,omitempty"`   // Name of the active schedule window
+	ShadowDropped         int64            `json:"shadow_dropped,omitempty"`    // Requests forwarded in shadow mode that enforcement would have dropped
+	ShadowRPMDropped      int64            `json:"shadow_rpm_dropped,omitempty"`
+	ShadowTPMDropped      int64            `json:"shadow_tpm_dropped,omitempty"`
+	ShadowUpstreamDropped int64            `json:"shadow_upstream_dropped,omitempty"`
 }
 
 // TokenBucket represents a token bucket for rate limiting
@@ -63,6 +263,32 @@ func (tb *TokenBucket) TryConsume(tokens int64) bool {
 	return false
 }
 
+// CanConsume reports whether the specified number of tokens is available
+// without consuming them, and if not, how long until it will be. The wait is
+// zero if the bucket can never hold that many tokens.
+func (tb *TokenBucket) CanConsume(tokens int64) (bool, time.Duration) {
+	tb.mutex.Lock()
+	defer tb.mutex.Unlock()
+
+	tb.refill()
+	if tb.tokens >= float64(tokens) {
+		return true, 0
+	}
+	if tokens > tb.capacity || tb.refillRate <= 0 {
+		return false, 0
+	}
+	return false, time.Duration((float64(tokens) - tb.tokens) / tb.refillRate * float64(time.Second))
+}
+
+// Refund returns previously consumed tokens to the bucket, up to its capacity
+func (tb *TokenBucket) Refund(tokens int64) {
+	tb.mutex.Lock()
+	defer tb.mutex.Unlock()
+
+	tb.refill()
+	tb.tokens = min(tb.tokens+float64(tokens), float64(tb.capacity))
+}
+
 // GetRemainingTokens returns the current number of tokens in the bucket
 func (tb *TokenBucket) GetRemainingTokens() int64 {
 	tb.mutex.Lock()
@@ -72,6 +298,32 @@ func (tb *TokenBucket) GetRemainingTokens() int64 {
 	return int64(tb.tokens)
 }
 
+// Status returns the bucket capacity, the tokens remaining and how long until
+// the bucket is full again
+func (tb *TokenBucket) Status() (int64, int64, time.Duration) {
+	tb.mutex.Lock()
+	defer tb.mutex.Unlock()
+
+	tb.refill()
+	var untilFull time.Duration
+	if tb.refillRate > 0 {
+		untilFull = time.Duration((float64(tb.capacity) - tb.tokens) / tb.refillRate * float64(time.Second))
+	}
+	return tb.capacity, int64(tb.tokens), untilFull
+}
+
+// SetLimits changes the bucket capacity and refill rate without resetting its
+// current level; tokens above the new capacity are discarded
+func (tb *TokenBucket) SetLimits(capacity int64, refillPerMinute int64) {
+	tb.mutex.Lock()
+	defer tb.mutex.Unlock()
+
+	tb.refill()
+	tb.capacity = capacity
+	tb.refillRate = float64(refillPerMinute) / 60.0
+	tb.tokens = min(tb.tokens, float64(capacity))
+}
+
 // refill adds tokens to the bucket based on elapsed time
 func (tb *TokenBucket) refill() {
 	now := time.Now()
@@ -106,4 +358,6 @@ var (
 	ErrRPMExceeded = RateLimitError{Type: "rpm_exceeded", Message: "Request rate limit exceeded"}
 	ErrTPMExceeded = RateLimitError{Type: "tpm_exceeded", Message: "Token rate limit exceeded"}
 	ErrClientNotFound = RateLimitError{Type: "client_not_found", Message: "Client not configured"}
+	ErrUpstreamSaturated = RateLimitError{Type: "upstream_saturated", Message: "Upstream provider rate limit nearly exhausted"}
+	ErrUnknownClient = RateLimitError{Type: "unknown_client", Message: "Client is not configured"}
 ) 
\ No newline at end of file
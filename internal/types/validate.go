@@ -0,0 +1,245 @@
+package types
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// MaxClientIDLength is the longest client ID accepted in a configuration
+const MaxClientIDLength = 128
+
+// clientIDPattern matches valid client IDs: printable, without whitespace,
+// and starting with a letter or digit
+var clientIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._:@/-]*$`)
+
+// FieldViolation describes why a single field is invalid
+type FieldViolation struct {
+	Field       string `json:"name"`
+	Description string `json:"reason"`
+}
+
+// ValidationError is returned for configurations and requests with invalid fields
+type ValidationError struct {
+	Violations []FieldViolation
+}
+
+func (e *ValidationError) Error() string {
+	descriptions := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		descriptions[i] = violation.Field + ": " + violation.Description
+	}
+	return "invalid fields: " + strings.Join(descriptions, "; ")
+}
+
+// NewValidationError returns a validation error for a single field
+func NewValidationError(field, description string) *ValidationError {
+	return &ValidationError{Violations: []FieldViolation{{Field: field, Description: description}}}
+}
+
+// Validate checks a configuration, returning a *ValidationError listing every invalid field
+func (c *ClientConfig) Validate() error {
+	var violations []FieldViolation
+
+	violations = validateName(violations, "client_id", c.ClientID)
+	if c.Template != "" {
+		violations = validateName(violations, "template", c.Template)
+	}
+
+	if c.RPM != nil && *c.RPM <= 0 {
+		violations = append(violations, FieldViolation{"rpm", "must be positive; omit it for no limit"})
+	}
+	if c.TPM != nil && *c.TPM <= 0 {
+		violations = append(violations, FieldViolation{"tpm", "must be positive; omit it for no limit"})
+	}
+	switch c.Mode {
+	case "", ModeEnforce, ModeShadow, ModeOff:
+	default:
+		violations = append(violations, FieldViolation{"mode", "must be enforce, shadow or off"})
+	}
+	if c.Schedule != nil {
+		violations = c.Schedule.validate(violations)
+	}
+
+	if len(violations) > 0 {
+		return &ValidationError{Violations: violations}
+	}
+	return nil
+}
+
+// weekdays maps the day names of schedule windows to days of the week
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Weekday returns the day of the week of a schedule window day name
+func Weekday(day string) (time.Weekday, bool) {
+	weekday, ok := weekdays[strings.ToLower(day)]
+	return weekday, ok
+}
+
+// ParseTimeOfDay parses an HH:MM time of a schedule window into minutes after midnight
+func ParseTimeOfDay(value string) (int, bool) {
+	parsed, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, false
+	}
+	return parsed.Hour()*60 + parsed.Minute(), true
+}
+
+// validate appends a violation for every invalid field of the schedule
+func (s *Schedule) validate(violations []FieldViolation) []FieldViolation {
+	if _, err := time.LoadLocation(s.Timezone); err != nil {
+		violations = append(violations, FieldViolation{"schedule.timezone", "must be an IANA time zone such as Europe/Berlin"})
+	}
+	if len(s.Windows) == 0 {
+		violations = append(violations, FieldViolation{"schedule.windows", "must not be empty; omit schedule for none"})
+	}
+
+	names := make(map[string]bool)
+	for i, window := range s.Windows {
+		field := fmt.Sprintf("schedule.windows[%d].", i)
+
+		switch {
+		case strings.TrimSpace(window.Name) == "":
+			violations = append(violations, FieldViolation{field + "name", "is required"})
+		case names[window.Name]:
+			violations = append(violations, FieldViolation{field + "name", "must be unique"})
+		}
+		names[window.Name] = true
+
+		for _, day := range window.Days {
+			if _, ok := Weekday(day); !ok {
+				violations = append(violations, FieldViolation{field + "days", fmt.Sprintf("unknown day %q; use mon, tue, wed, thu, fri, sat or sun", day)})
+			}
+		}
+
+		start, startOK := ParseTimeOfDay(window.Start)
+		if !startOK {
+			violations = append(violations, FieldViolation{field + "start", "must be a time of day as HH:MM"})
+		}
+		end, endOK := ParseTimeOfDay(window.End)
+		if !endOK {
+			violations = append(violations, FieldViolation{field + "end", "must be a time of day as HH:MM"})
+		}
+		if startOK && endOK && start == end {
+			violations = append(violations, FieldViolation{field + "end", "must differ from start"})
+		}
+
+		if window.RPM != nil && *window.RPM <= 0 {
+			violations = append(violations, FieldViolation{field + "rpm", "must be positive"})
+		}
+		if window.TPM != nil && *window.TPM <= 0 {
+			violations = append(violations, FieldViolation{field + "tpm", "must be positive"})
+		}
+	}
+	return violations
+}
+
+// Validate checks a limit template, returning a *ValidationError listing every invalid field
+func (t *LimitTemplate) Validate() error {
+	violations := validateName(nil, "name", t.Name)
+
+	if t.RPM != nil && *t.RPM <= 0 {
+		violations = append(violations, FieldViolation{"rpm", "must be positive; omit it for no limit"})
+	}
+	if t.TPM != nil && *t.TPM <= 0 {
+		violations = append(violations, FieldViolation{"tpm", "must be positive; omit it for no limit"})
+	}
+
+	if len(violations) > 0 {
+		return &ValidationError{Violations: violations}
+	}
+	return nil
+}
+
+// Validate checks a client rule, returning a *ValidationError listing every invalid field
+func (r *ClientRule) Validate() error {
+	violations := validateName(nil, "name", r.Name)
+
+	switch {
+	case r.Pattern == "":
+		violations = append(violations, FieldViolation{"pattern", "is required"})
+	case r.Regex:
+		if _, err := regexp.Compile(r.Pattern); err != nil {
+			violations = append(violations, FieldViolation{"pattern", "must be a valid regular expression: " + err.Error()})
+		}
+	default:
+		if _, err := path.Match(r.Pattern, ""); err != nil {
+			violations = append(violations, FieldViolation{"pattern", "must be a valid glob"})
+		}
+	}
+	if r.Template != "" {
+		violations = validateName(violations, "template", r.Template)
+	}
+
+	if r.RPM != nil && *r.RPM <= 0 {
+		violations = append(violations, FieldViolation{"rpm", "must be positive; omit it for no limit"})
+	}
+	if r.TPM != nil && *r.TPM <= 0 {
+		violations = append(violations, FieldViolation{"tpm", "must be positive; omit it for no limit"})
+	}
+
+	if len(violations) > 0 {
+		return &ValidationError{Violations: violations}
+	}
+	return nil
+}
+
+// MaxOverrideReasonLength is the longest reason accepted for a limit override
+const MaxOverrideReasonLength = 512
+
+// Validate checks a limit override, returning a *ValidationError listing every invalid field
+func (o *LimitOverride) Validate() error {
+	var violations []FieldViolation
+
+	switch {
+	case strings.TrimSpace(o.Reason) == "":
+		violations = append(violations, FieldViolation{"reason", "is required"})
+	case len(o.Reason) > MaxOverrideReasonLength:
+		violations = append(violations, FieldViolation{"reason", fmt.Sprintf("must be at most %d characters", MaxOverrideReasonLength)})
+	}
+
+	if o.RPM == nil && o.TPM == nil && o.RPMDelta == 0 && o.TPMDelta == 0 {
+		violations = append(violations, FieldViolation{"rpm", "an override must change rpm or tpm, by value or delta"})
+	}
+	if o.RPM != nil && o.RPMDelta != 0 {
+		violations = append(violations, FieldViolation{"rpm_delta", "cannot be combined with rpm"})
+	}
+	if o.TPM != nil && o.TPMDelta != 0 {
+		violations = append(violations, FieldViolation{"tpm_delta", "cannot be combined with tpm"})
+	}
+	if o.RPM != nil && *o.RPM <= 0 {
+		violations = append(violations, FieldViolation{"rpm", "must be positive"})
+	}
+	if o.TPM != nil && *o.TPM <= 0 {
+		violations = append(violations, FieldViolation{"tpm", "must be positive"})
+	}
+
+	if len(violations) > 0 {
+		return &ValidationError{Violations: violations}
+	}
+	return nil
+}
+
+// validateName appends a violation for field if name is not a valid client
+// ID, template or rule name
+func validateName(violations []FieldViolation, field, name string) []FieldViolation {
+	switch {
+	case strings.TrimSpace(name) == "":
+		violations = append(violations, FieldViolation{field, "is required"})
+	case len(name) > MaxClientIDLength:
+		violations = append(violations, FieldViolation{field, fmt.Sprintf("must be at most %d characters", MaxClientIDLength)})
+	case !clientIDPattern.MatchString(name):
+		violations = append(violations, FieldViolation{field, "must start with a letter or digit and contain only letters, digits and . _ : @ / -"})
+	}
+	return violations
+}